@@ -0,0 +1,58 @@
+// gencert создаёт самоподписанный сертификат для локальной разработки:
+//
+//	go run ./cmd/gencert -hosts localhost,127.0.0.1 -out certs
+//
+// и печатает строку TLS_CERTS для .env. Сертификат — лист без права
+// подписи, клиенту его передают напрямую: curl --cacert certs/cert.pem
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"web-server/internal/certs"
+)
+
+func main() {
+	hosts := flag.String("hosts", "localhost,127.0.0.1,::1", "имена и IP через запятую")
+	out := flag.String("out", "certs", "каталог для cert.pem и key.pem")
+	days := flag.Int("days", 365, "срок действия в днях")
+	flag.Parse()
+
+	var list []string
+	for _, h := range strings.Split(*hosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			list = append(list, h)
+		}
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(os.Stderr, "нужен хотя бы один -hosts")
+		os.Exit(2)
+	}
+
+	certPEM, keyPEM, err := certs.SelfSigned(list, time.Duration(*days)*24*time.Hour)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ошибка генерации сертификата: %v\n", err)
+		os.Exit(1)
+	}
+
+	certFile := filepath.Join(*out, "cert.pem")
+	keyFile := filepath.Join(*out, "key.pem")
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "ошибка создания каталога: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "ошибка записи сертификата: %v\n", err)
+		os.Exit(1)
+	}
+	// ключ читает только владелец
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "ошибка записи ключа: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("TLS_CERTS=%s=%s\n", certFile, keyFile)
+}
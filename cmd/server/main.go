@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os/signal"
+	"syscall"
 	"web-server/internal/config"
 	"web-server/internal/server"
 	"web-server/internal/storage"
@@ -50,6 +53,34 @@ func main() {
 	// logger.Log.Info("Хранилище пользователей инициализировано")
 
 	logger.Log.Info("Запуск сервера", "host", cfg.Host, "port", cfg.Port)
-	server.Start(cfg, store)
+	srv, err := server.New(cfg, store)
+	if err != nil {
+		logger.Log.Error("не удалось создать сервер", "error", err)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Start()
+	}()
 
+	select {
+	case <-ctx.Done():
+		logger.Log.Info("получен сигнал остановки")
+	case err := <-errCh:
+		if err != nil {
+			logger.Log.Error("сервер остановлен с ошибкой", "error", err)
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Log.Warn("остановка сервера завершена с ошибкой", "error", err)
+		return
+	}
+	logger.Log.Info("сервер остановлен")
 }
@@ -0,0 +1,143 @@
+// Package certs держит TLS-сертификаты сервера: выбирает сертификат по SNI
+// и перечитывает файлы, когда они меняются на диске, без перезапуска
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"web-server/internal/config"
+	"web-server/pkg/logger"
+)
+
+// Store — набор сертификатов, который можно подменять на лету.
+// Чтение идёт на каждом рукопожатии, поэтому набор хранится атомарно
+type Store struct {
+	pairs []config.TLSCert
+	certs atomic.Pointer[[]*tls.Certificate]
+
+	mu    sync.Mutex // одна перезагрузка за раз
+	stamp string     // размеры и время изменения файлов при последней загрузке
+}
+
+// Load читает все пары сертификат/ключ. Первая пара — сертификат
+// по умолчанию для клиентов без SNI или с незнакомым именем
+func Load(pairs []config.TLSCert) (*Store, error) {
+	if len(pairs) == 0 {
+		return nil, errors.New("certs: no certificates configured")
+	}
+	s := &Store{pairs: pairs}
+	stamp, err := s.fileStamp()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.stamp = stamp
+	return s, nil
+}
+
+func (s *Store) load() error {
+	certs := make([]*tls.Certificate, 0, len(s.pairs))
+	for _, p := range s.pairs {
+		cert, err := tls.LoadX509KeyPair(p.CertFile, p.KeyFile)
+		if err != nil {
+			return fmt.Errorf("certs: load %s: %w", p.CertFile, err)
+		}
+		certs = append(certs, &cert)
+	}
+	s.certs.Store(&certs)
+	return nil
+}
+
+// GetCertificate выбирает сертификат для рукопожатия — для tls.Config.
+// Подходящий по имени и алгоритму, иначе сертификат по умолчанию
+func (s *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certs := *s.certs.Load()
+	for _, cert := range certs {
+		if hello.SupportsCertificate(cert) == nil {
+			return cert, nil
+		}
+	}
+	return certs[0], nil
+}
+
+// Watch проверяет файлы раз в interval и перечитывает набор, если они
+// изменились. Битые файлы (например, записанные наполовину) не применяются —
+// остаются прежние сертификаты до следующей удачной попытки.
+// Блокируется до отмены ctx
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Reload()
+		}
+	}
+}
+
+// Reload перечитывает сертификаты, если файлы изменились с прошлой загрузки.
+// Возвращает true, если набор обновлён
+func (s *Store) Reload() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stamp, err := s.fileStamp()
+	if err != nil {
+		logger.Log.Warn("не удалось проверить файлы сертификатов", "error", err)
+		return false
+	}
+	if stamp == s.stamp {
+		return false
+	}
+	if err := s.load(); err != nil {
+		logger.Log.Error("сертификаты изменились, но не загружаются — оставлены прежние", "error", err)
+		return false
+	}
+	s.stamp = stamp
+	logger.Log.Info("сертификаты перезагружены", "count", len(s.pairs))
+	return true
+}
+
+func (s *Store) fileStamp() (string, error) {
+	var stamp string
+	for _, p := range s.pairs {
+		for _, name := range []string{p.CertFile, p.KeyFile} {
+			info, err := os.Stat(name)
+			if err != nil {
+				return "", err
+			}
+			stamp += fmt.Sprintf("%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return stamp, nil
+}
+
+// TLSConfig собирает tls.Config сервера из настроек и набора сертификатов.
+// С TLS_CLIENT_AUTH подключает проверку клиентских сертификатов
+func TLSConfig(cfg *config.Config, store *Store) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		GetCertificate: store.GetCertificate,
+		MinVersion:     cfg.TLSMinVersion,
+		CipherSuites:   cfg.TLSCipherSuites,
+		// HTTP/2 наш стек не умеет — договариваемся только о HTTP/1.1
+		NextProtos: []string{"http/1.1"},
+		ClientAuth: clientAuth(cfg.TLSClientAuth),
+	}
+	if tlsConfig.ClientAuth != tls.NoClientCert {
+		pool, err := loadClientCAs(cfg.TLSClientCA)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,254 @@
+package certs_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"web-server/internal/certs"
+	"web-server/internal/config"
+	"web-server/internal/servertest"
+)
+
+// dialName подключается к серверу с SNI name и проверяет сертификат по roots
+func dialName(t *testing.T, addr, name string, roots *x509.CertPool) (*tls.Conn, error) {
+	t.Helper()
+	conn, err := tls.Dial("tcp4", addr, &tls.Config{ServerName: name, RootCAs: roots})
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, err
+}
+
+func certPool(t *testing.T, pairs ...config.TLSCert) *x509.CertPool {
+	t.Helper()
+	pool := x509.NewCertPool()
+	for _, p := range pairs {
+		data, err := os.ReadFile(p.CertFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool.AppendCertsFromPEM(data)
+	}
+	return pool
+}
+
+func TestServeTLS(t *testing.T) {
+	api := servertest.WriteCert(t, "api.test", "127.0.0.1")
+	docs := servertest.WriteCert(t, "docs.test")
+	s := servertest.New(t, func(cfg *config.Config) {
+		cfg.TLSCerts = []config.TLSCert{api, docs}
+	})
+	roots := certPool(t, api, docs)
+
+	s.Do(t, "GET", "/api/v1/users", nil).AssertStatus(t, 200)
+
+	// SNI выбирает сертификат, клиент проверяет цепочку и имя
+	for _, name := range []string{"api.test", "docs.test"} {
+		conn, err := dialName(t, s.Addr, name, roots)
+		if err != nil {
+			t.Fatalf("dial %s: %v", name, err)
+		}
+		if proto := conn.ConnectionState().NegotiatedProtocol; proto != "" && proto != "http/1.1" {
+			t.Errorf("ALPN = %q", proto)
+		}
+	}
+	// незнакомое имя получает сертификат по умолчанию — первый
+	conn, err := tls.Dial("tcp4", s.Addr, &tls.Config{ServerName: "other.test", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if got := conn.ConnectionState().PeerCertificates[0].DNSNames; got[0] != "api.test" {
+		t.Errorf("default certificate = %v", got)
+	}
+
+	// открытый HTTP на TLS-порту ответа не получает
+	raw, err := net.Dial("tcp4", s.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	raw.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(raw, "GET /api/v1/users HTTP/1.1\r\nHost: x\r\n\r\n")
+	if reply, err := io.ReadAll(raw); err != nil || bytes.Contains(reply, []byte("HTTP/1.1")) {
+		t.Errorf("plain HTTP reply = %q, %v", reply, err)
+	}
+}
+
+func TestMinVersion(t *testing.T) {
+	pair := servertest.WriteCert(t, "api.test")
+	s := servertest.New(t, func(cfg *config.Config) {
+		cfg.TLSCerts = []config.TLSCert{pair}
+		cfg.TLSMinVersion = tls.VersionTLS13
+	})
+
+	_, err := tls.Dial("tcp4", s.Addr, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12})
+	if err == nil {
+		t.Fatal("TLS 1.2 handshake succeeded with TLS_MIN_VERSION=1.3")
+	}
+	conn, err := tls.Dial("tcp4", s.Addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestReload(t *testing.T) {
+	pair := servertest.WriteCert(t, "old.test")
+	store, err := certs.Load([]config.TLSCert{pair})
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := func() string {
+		cert, err := store.GetCertificate(&tls.ClientHelloInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.DNSNames[0]
+	}
+
+	if store.Reload() {
+		t.Error("reload without changes")
+	}
+
+	// новый сертификат на месте старого
+	replace(t, pair, servertest.WriteCert(t, "new.test"))
+	if !store.Reload() || served() != "new.test" {
+		t.Fatalf("after reload served %s", served())
+	}
+
+	// наполовину записанный файл не применяется
+	if err := os.WriteFile(pair.CertFile, []byte("-----BEGIN CERT"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if store.Reload() || served() != "new.test" {
+		t.Fatalf("broken file applied, served %s", served())
+	}
+}
+
+func TestWatch(t *testing.T) {
+	pair := servertest.WriteCert(t, "old.test")
+	s := servertest.New(t, func(cfg *config.Config) {
+		cfg.TLSCerts = []config.TLSCert{pair}
+		cfg.TLSReloadInterval = 10 * time.Millisecond
+	})
+
+	next := servertest.WriteCert(t, "new.test")
+	replace(t, pair, next)
+	roots := certPool(t, next)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := dialName(t, s.Addr, "new.test", roots)
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("new certificate not served: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	rules := []config.ClientIdentity{
+		{Match: "uri:spiffe://corp/ns/prod/sa/billing", Service: "billing"},
+		{Match: "dns:Reports.Internal", Service: "reports"},
+		{Match: "email:alice@corp.test", UserID: 1},
+		{Match: "ip:10.0.0.7", Service: "cron"},
+		{Match: "cn:bob", UserID: 2},
+	}
+	spiffe, _ := url.Parse("spiffe://corp/ns/prod/sa/billing")
+	tests := []struct {
+		name string
+		cert x509.Certificate
+		want string // Match правила, "" — не опознан
+	}{
+		{"uri", x509.Certificate{URIs: []*url.URL{spiffe}}, "uri:spiffe://corp/ns/prod/sa/billing"},
+		{"dns case-insensitive", x509.Certificate{DNSNames: []string{"reports.internal"}}, "dns:Reports.Internal"},
+		{"email", x509.Certificate{EmailAddresses: []string{"Alice@corp.test"}}, "email:alice@corp.test"},
+		{"ip", x509.Certificate{IPAddresses: []net.IP{net.ParseIP("10.0.0.7")}}, "ip:10.0.0.7"},
+		{"cn", x509.Certificate{Subject: pkix.Name{CommonName: "bob"}}, "cn:bob"},
+		// правила проверяются по порядку
+		{"first rule wins", x509.Certificate{Subject: pkix.Name{CommonName: "bob"}, URIs: []*url.URL{spiffe}},
+			"uri:spiffe://corp/ns/prod/sa/billing"},
+		{"cn is exact", x509.Certificate{Subject: pkix.Name{CommonName: "Bob"}}, ""},
+		{"dns in cn only", x509.Certificate{Subject: pkix.Name{CommonName: "reports.internal"}}, ""},
+		{"none", x509.Certificate{}, ""},
+	}
+	for _, tt := range tests {
+		rule, ok := certs.Identify(&tt.cert, rules)
+		if ok != (tt.want != "") || rule.Match != tt.want {
+			t.Errorf("%s: Identify = %+v, %v; want %q", tt.name, rule, ok, tt.want)
+		}
+	}
+}
+
+// replace переписывает файлы пары dst содержимым src
+func replace(t *testing.T, dst, src config.TLSCert) {
+	t.Helper()
+	for _, f := range [][2]string{{src.CertFile, dst.CertFile}, {src.KeyFile, dst.KeyFile}} {
+		data, err := os.ReadFile(f[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f[1], data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// сертификат для разработки — лист: ему можно доверять напрямую,
+// но подписанное его ключом не проверяется
+func TestSelfSignedIsLeaf(t *testing.T) {
+	certPEM, keyPEM, err := certs.SelfSigned([]string{"localhost", "127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := pair.Leaf
+	if leaf.IsCA || leaf.KeyUsage&x509.KeyUsageCertSign != 0 {
+		t.Fatalf("IsCA = %v, KeyUsage = %b", leaf.IsCA, leaf.KeyUsage)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	for _, name := range []string{"localhost", "127.0.0.1"} {
+		if _, err := leaf.Verify(x509.VerifyOptions{DNSName: name, Roots: roots}); err != nil {
+			t.Errorf("verify %s: %v", name, err)
+		}
+	}
+
+	child, err := x509.CreateCertificate(nil, &x509.Certificate{
+		SerialNumber: leaf.SerialNumber,
+		Subject:      pkix.Name{CommonName: "evil.test"},
+		DNSNames:     []string{"evil.test"},
+		NotBefore:    leaf.NotBefore,
+		NotAfter:     leaf.NotAfter,
+	}, leaf, leaf.PublicKey, pair.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued, err := x509.ParseCertificate(child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issued.Verify(x509.VerifyOptions{DNSName: "evil.test", Roots: roots}); err == nil {
+		t.Fatal("certificate signed by the dev leaf is trusted")
+	}
+}
@@ -0,0 +1,82 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"web-server/internal/config"
+)
+
+// clientAuth переводит режим TLS_CLIENT_AUTH в политику рукопожатия.
+// request тоже проверяет цепочку — непроверенный сертификат хуже, чем никакой
+func clientAuth(mode string) tls.ClientAuthType {
+	switch mode {
+	case "require":
+		return tls.RequireAndVerifyClientCert
+	case "request":
+		return tls.VerifyClientCertIfGiven
+	default:
+		return tls.NoClientCert
+	}
+}
+
+// loadClientCAs читает PEM-бандл доверенных CA для клиентских сертификатов
+func loadClientCAs(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("certs: client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("certs: client CA: no certificates in %s", file)
+	}
+	return pool, nil
+}
+
+// Identify ищет первое правило, подходящее к сертификату клиента.
+// Сравниваются CN субъекта и SAN: DNS-имена, URI, email и IP
+func Identify(cert *x509.Certificate, rules []config.ClientIdentity) (config.ClientIdentity, bool) {
+	for _, rule := range rules {
+		kind, value, _ := strings.Cut(rule.Match, ":")
+		if hasField(cert, kind, value) {
+			return rule, true
+		}
+	}
+	return config.ClientIdentity{}, false
+}
+
+func hasField(cert *x509.Certificate, kind, value string) bool {
+	switch kind {
+	case "cn":
+		return cert.Subject.CommonName == value
+	case "dns":
+		for _, name := range cert.DNSNames {
+			if strings.EqualFold(name, value) {
+				return true
+			}
+		}
+	case "uri":
+		for _, uri := range cert.URIs {
+			if uri.String() == value {
+				return true
+			}
+		}
+	case "email":
+		for _, email := range cert.EmailAddresses {
+			if strings.EqualFold(email, value) {
+				return true
+			}
+		}
+	case "ip":
+		ip := net.ParseIP(value)
+		for _, addr := range cert.IPAddresses {
+			if addr.Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"crypto/tls"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"web-server/pkg/logger"
 
@@ -17,7 +21,71 @@ type Config struct {
 	ApiBasePath  string
 	JwtSecret    string
 	JwtExpires   int
+	AuthRequired bool // изменение пользователей только с JWT
 	DatabasePath string
+
+	KeepAliveTimeout     time.Duration // сколько ждать следующий запрос на keep-alive соединении
+	KeepAliveMaxRequests int           // максимум запросов на одно соединение
+
+	ReadHeaderTimeout time.Duration // на строку запроса и заголовки
+	ReadBodyTimeout   time.Duration // на тело запроса
+	WriteTimeout      time.Duration // на обработку и отправку ответа
+
+	MaxRequestLineBytes int   // длиннее — 414
+	MaxHeaderBytes      int   // все заголовки вместе, больше — 431
+	MaxHeaderCount      int   // больше — 431
+	MaxBodyBytes        int64 // по умолчанию для всех маршрутов, больше — 413
+
+	MultipartMemoryBytes   int64  // файлы больше уходят во временный файл
+	MultipartMaxFileBytes  int64  // предел одного файла
+	MultipartMaxTotalBytes int64  // предел всех частей формы
+	MultipartMaxParts      int    // предел числа частей
+	UploadTempDir          string // куда складывать временные файлы, "" — системный tmp
+
+	CompressEnabled  bool     // сжимать ответы gzip/deflate по Accept-Encoding
+	CompressMinBytes int64    // тела меньше отдаются как есть
+	CompressTypes    []string // какие Content-Type сжимать, "text/" — весь text/*
+
+	StaticMounts []StaticMount // STATIC_MOUNTS=/app=web/dist,/docs=docs
+	StaticIndex  []string      // index-файлы каталогов в порядке приоритета
+
+	TLSCerts          []TLSCert     // пусто — без TLS; первая пара — сертификат по умолчанию
+	TLSMinVersion     uint16        // tls.VersionTLS12 или tls.VersionTLS13
+	TLSCipherSuites   []uint16      // только для TLS 1.2, пусто — набор Go по умолчанию
+	TLSReloadInterval time.Duration // как часто проверять файлы сертификатов, 0 — не проверять
+
+	TLSClientAuth       string           // mTLS: off, request (проверять, если прислан) или require
+	TLSClientCA         string           // PEM-бандл CA для клиентских сертификатов
+	TLSClientIdentities []ClientIdentity // кого считать каким пользователем или сервисом
+
+	WSMaxMessageBytes int64         // предел сообщения клиента WebSocket, больше — закрытие с 1009
+	WSPingInterval    time.Duration // как часто пинговать клиента; без ответа за два интервала — отключение
+
+	StrictParsing bool // PARSER_MODE=strict — отвергать всё, что RFC 9112 разрешает отвергать
+
+	PathRedirectStatus int // 301 или 308 — редирект на нормализованный путь (не GET/HEAD — всегда 308), 0 — без редиректа
+
+	ShutdownTimeout time.Duration // сколько ждать активные соединения при остановке
+}
+
+// StaticMount — каталог Dir, отдаваемый по префиксу пути Prefix
+type StaticMount struct {
+	Prefix string
+	Dir    string
+}
+
+// TLSCert — пара файлов PEM: сертификат (с цепочкой) и ключ
+type TLSCert struct {
+	CertFile string
+	KeyFile  string
+}
+
+// ClientIdentity сопоставляет поле клиентского сертификата с пользователем
+// или сервисом. Match — "cn:", "dns:", "uri:", "email:" или "ip:" и значение
+type ClientIdentity struct {
+	Match   string
+	UserID  int    // 0 — не пользователь
+	Service string // "" — не сервис
 }
 
 // loadCfg загружает конфигурацию из файла
@@ -78,11 +146,272 @@ func LoadCfg() (*Config, error) {
 	}
 	cfg.JwtExpires = expires
 
+	if cfg.AuthRequired, err = getEnvBool("AUTH_REQUIRED", false); err != nil {
+		return nil, err
+	}
+
 	databasePath := os.Getenv("DATABASE_PATH")
 	if databasePath == "" {
 		databasePath = "web-serverDB.sqlite"
 	}
 	cfg.DatabasePath = databasePath
 
+	if cfg.KeepAliveTimeout, err = getEnvSeconds("KEEP_ALIVE_TIMEOUT_SEC", 5); err != nil {
+		return nil, err
+	}
+	if cfg.KeepAliveMaxRequests, err = getEnvInt("KEEP_ALIVE_MAX_REQUESTS", 100); err != nil {
+		return nil, err
+	}
+	if cfg.ReadHeaderTimeout, err = getEnvSeconds("READ_HEADER_TIMEOUT_SEC", 10); err != nil {
+		return nil, err
+	}
+	if cfg.ReadBodyTimeout, err = getEnvSeconds("READ_BODY_TIMEOUT_SEC", 30); err != nil {
+		return nil, err
+	}
+	if cfg.WriteTimeout, err = getEnvSeconds("WRITE_TIMEOUT_SEC", 30); err != nil {
+		return nil, err
+	}
+	if cfg.MaxRequestLineBytes, err = getEnvInt("MAX_REQUEST_LINE_BYTES", 8*1024); err != nil {
+		return nil, err
+	}
+	if cfg.MaxHeaderBytes, err = getEnvInt("MAX_HEADER_BYTES", 64*1024); err != nil {
+		return nil, err
+	}
+	if cfg.MaxHeaderCount, err = getEnvInt("MAX_HEADER_COUNT", 100); err != nil {
+		return nil, err
+	}
+	if cfg.MaxBodyBytes, err = getEnvInt64("MAX_BODY_BYTES", 10<<20); err != nil {
+		return nil, err
+	}
+	if cfg.MultipartMemoryBytes, err = getEnvInt64("MULTIPART_MEMORY_BYTES", 1<<20); err != nil {
+		return nil, err
+	}
+	if cfg.MultipartMaxFileBytes, err = getEnvInt64("MULTIPART_MAX_FILE_BYTES", 10<<20); err != nil {
+		return nil, err
+	}
+	if cfg.MultipartMaxTotalBytes, err = getEnvInt64("MULTIPART_MAX_TOTAL_BYTES", 32<<20); err != nil {
+		return nil, err
+	}
+	if cfg.MultipartMaxParts, err = getEnvInt("MULTIPART_MAX_PARTS", 100); err != nil {
+		return nil, err
+	}
+	cfg.UploadTempDir = os.Getenv("UPLOAD_TEMP_DIR")
+	if cfg.CompressEnabled, err = getEnvBool("COMPRESS_ENABLED", true); err != nil {
+		return nil, err
+	}
+	if cfg.CompressMinBytes, err = getEnvInt64("COMPRESS_MIN_BYTES", 1024); err != nil {
+		return nil, err
+	}
+	cfg.CompressTypes = getEnvList("COMPRESS_TYPES",
+		"application/json,application/javascript,image/svg+xml,text/")
+	for i, t := range cfg.CompressTypes {
+		cfg.CompressTypes[i] = strings.ToLower(t) // типы MIME сравниваются без учёта регистра
+	}
+	if cfg.StaticMounts, err = parseStaticMounts(os.Getenv("STATIC_MOUNTS")); err != nil {
+		return nil, err
+	}
+	cfg.StaticIndex = getEnvList("STATIC_INDEX", "index.html")
+	if err := loadTLS(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.WSMaxMessageBytes, err = getEnvInt64("WS_MAX_MESSAGE_BYTES", 64<<10); err != nil {
+		return nil, err
+	}
+	if cfg.WSMaxMessageBytes <= 0 {
+		return nil, fmt.Errorf("WS_MAX_MESSAGE_BYTES должен быть больше 0")
+	}
+	if cfg.WSPingInterval, err = getEnvSeconds("WS_PING_INTERVAL_SEC", 30); err != nil {
+		return nil, err
+	}
+	if cfg.WSPingInterval <= 0 {
+		return nil, fmt.Errorf("WS_PING_INTERVAL_SEC должен быть больше 0")
+	}
+	switch mode := os.Getenv("PARSER_MODE"); mode {
+	case "", "strict":
+		cfg.StrictParsing = true
+	case "lenient":
+		cfg.StrictParsing = false
+	default:
+		return nil, fmt.Errorf("PARSER_MODE должен быть strict или lenient, получено %q", mode)
+	}
+	if cfg.PathRedirectStatus, err = getEnvInt("PATH_REDIRECT_STATUS", 0); err != nil {
+		return nil, err
+	}
+	if s := cfg.PathRedirectStatus; s != 0 && s != 301 && s != 308 {
+		return nil, fmt.Errorf("PATH_REDIRECT_STATUS должен быть 0, 301 или 308, получено %d", s)
+	}
+	if cfg.ShutdownTimeout, err = getEnvSeconds("SHUTDOWN_TIMEOUT_SEC", 10); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
+
+// getEnvInt читает целое из env, если переменная не задана — возвращает def
+func getEnvInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
+
+// getEnvInt64 читает int64 из env, для размеров в байтах
+func getEnvInt64(key string, def int64) (int64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// getEnvBool читает true/false из env
+func getEnvBool(key string, def bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+// getEnvList читает список через запятую, пустые элементы отбрасываются
+func getEnvList(key string, def string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		v = def
+	}
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseStaticMounts разбирает список "префикс=каталог" через запятую
+func parseStaticMounts(v string) ([]StaticMount, error) {
+	var mounts []StaticMount
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		prefix, dir, ok := strings.Cut(item, "=")
+		prefix, dir = strings.TrimSpace(prefix), strings.TrimSpace(dir)
+		if !ok || !strings.HasPrefix(prefix, "/") || dir == "" {
+			return nil, fmt.Errorf("STATIC_MOUNTS: ожидается /префикс=каталог, получено %q", item)
+		}
+		mounts = append(mounts, StaticMount{Prefix: strings.TrimSuffix(prefix, "/"), Dir: dir})
+	}
+	return mounts, nil
+}
+
+// loadTLS читает TLS_CERTS ("cert.pem=key.pem,...") и параметры рукопожатия
+func loadTLS(cfg *Config) error {
+	for _, item := range getEnvList("TLS_CERTS", "") {
+		certFile, keyFile, ok := strings.Cut(item, "=")
+		certFile, keyFile = strings.TrimSpace(certFile), strings.TrimSpace(keyFile)
+		if !ok || certFile == "" || keyFile == "" {
+			return fmt.Errorf("TLS_CERTS: ожидается сертификат=ключ, получено %q", item)
+		}
+		cfg.TLSCerts = append(cfg.TLSCerts, TLSCert{CertFile: certFile, KeyFile: keyFile})
+	}
+
+	switch v := os.Getenv("TLS_MIN_VERSION"); v {
+	case "", "1.2":
+		cfg.TLSMinVersion = tls.VersionTLS12
+	case "1.3":
+		cfg.TLSMinVersion = tls.VersionTLS13
+	default:
+		return fmt.Errorf("TLS_MIN_VERSION должен быть 1.2 или 1.3, получено %q", v)
+	}
+
+	// небезопасные наборы из tls.InsecureCipherSuites не принимаем
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	for _, name := range getEnvList("TLS_CIPHER_SUITES", "") {
+		id, ok := known[strings.ToUpper(name)]
+		if !ok {
+			return fmt.Errorf("TLS_CIPHER_SUITES: неизвестный или небезопасный набор %q", name)
+		}
+		cfg.TLSCipherSuites = append(cfg.TLSCipherSuites, id)
+	}
+
+	var err error
+	if cfg.TLSReloadInterval, err = getEnvSeconds("TLS_RELOAD_INTERVAL_SEC", 10); err != nil {
+		return err
+	}
+	return loadClientAuth(cfg)
+}
+
+// loadClientAuth читает настройки mTLS. TLS_CLIENT_IDENTITIES —
+// "cn:alice=user:3,dns:billing.internal=service:billing"
+func loadClientAuth(cfg *Config) error {
+	cfg.TLSClientAuth = os.Getenv("TLS_CLIENT_AUTH")
+	switch cfg.TLSClientAuth {
+	case "":
+		cfg.TLSClientAuth = "off"
+	case "off", "request", "require":
+	default:
+		return fmt.Errorf("TLS_CLIENT_AUTH должен быть off, request или require, получено %q", cfg.TLSClientAuth)
+	}
+	cfg.TLSClientCA = os.Getenv("TLS_CLIENT_CA")
+	if cfg.TLSClientAuth != "off" && (cfg.TLSClientCA == "" || len(cfg.TLSCerts) == 0) {
+		return fmt.Errorf("TLS_CLIENT_AUTH=%s требует TLS_CLIENT_CA и TLS_CERTS", cfg.TLSClientAuth)
+	}
+
+	for _, item := range getEnvList("TLS_CLIENT_IDENTITIES", "") {
+		id, err := parseClientIdentity(item)
+		if err != nil {
+			return fmt.Errorf("TLS_CLIENT_IDENTITIES: %w", err)
+		}
+		cfg.TLSClientIdentities = append(cfg.TLSClientIdentities, id)
+	}
+	return nil
+}
+
+// parseClientIdentity разбирает "поле:значение=user:id" или "поле:значение=service:имя".
+// В URI может быть "=", поэтому делим по последнему
+func parseClientIdentity(item string) (ClientIdentity, error) {
+	i := strings.LastIndex(item, "=")
+	if i < 0 {
+		return ClientIdentity{}, fmt.Errorf("ожидается поле:значение=user:id или service:имя, получено %q", item)
+	}
+	match, target := strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+	kind, value, _ := strings.Cut(match, ":")
+	switch kind {
+	case "cn", "dns", "uri", "email", "ip":
+	default:
+		return ClientIdentity{}, fmt.Errorf("неизвестное поле сертификата %q в %q", kind, item)
+	}
+	if value == "" {
+		return ClientIdentity{}, fmt.Errorf("пустое значение в %q", item)
+	}
+
+	id := ClientIdentity{Match: match}
+	targetKind, name, _ := strings.Cut(target, ":")
+	switch {
+	case targetKind == "user":
+		userID, err := strconv.Atoi(name)
+		if err != nil || userID <= 0 {
+			return ClientIdentity{}, fmt.Errorf("некорректный id пользователя в %q", item)
+		}
+		id.UserID = userID
+	case targetKind == "service" && name != "":
+		id.Service = name
+	default:
+		return ClientIdentity{}, fmt.Errorf("ожидается user:id или service:имя, получено %q", target)
+	}
+	return id, nil
+}
+
+// getEnvSeconds читает длительность в секундах из env
+func getEnvSeconds(key string, def int) (time.Duration, error) {
+	sec, err := getEnvInt(key, def)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(sec) * time.Second, nil
+}
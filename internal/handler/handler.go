@@ -1,306 +1,319 @@
 package handler
 
 import (
-	"bufio"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net"
 	"strconv"
-	"strings"
+	"time"
 	"web-server/internal/config"
+	"web-server/internal/httpx"
+	"web-server/internal/middleware"
 	"web-server/internal/model"
+	"web-server/internal/router"
+	"web-server/internal/static"
 	"web-server/internal/storage"
 
-	//"web-server/pkg/jwt"
+	"web-server/pkg/jwt"
 	"web-server/pkg/logger"
 )
 
-type Request struct {
-	Method  string
-	Path    string
-	Version string
-	Query   map[string]string
-	Body    []byte
-	Uploads []*UploadReq
-	Headers map[string]string
+// Handler содержит обработчики API пользователей
+type Handler struct {
+	store *storage.Storage
+	cfg   *config.Config
 }
 
-type UploadReq struct {
-	Filename    string
-	Size        int
-	ContentType string
-	Content     string
-}
-
-// parseRequest читает HTTP-запрос из conn и возвращает Request
-func parseRequest(conn net.Conn) (*Request, error) {
-	reader := bufio.NewReader(conn)
+// NewRouter регистрирует все маршруты API и каталоги статики.
+// Ошибка — каталог статики не открывается
+func NewRouter(store *storage.Storage, cfg *config.Config) (*router.Router, error) {
+	h := &Handler{store: store, cfg: cfg}
 
-	// Читаем первую строку запроса: Method Path Version
-	line, err := reader.ReadString('\n')
-	if err != nil {
-		return nil, fmt.Errorf("failed to read request line: %w", err)
+	r := router.New()
+	r.Use(middleware.Logging, middleware.Recover, middleware.Timing)
+	if cfg.TLSClientAuth != "off" {
+		r.Use(middleware.PeerIdentity(cfg, store.GetUser))
 	}
-	line = strings.TrimSpace(line)
-	parts := strings.Fields(line)
-	if len(parts) < 3 {
-		return nil, fmt.Errorf("invalid request line: %s", line)
+	if cfg.CompressEnabled {
+		r.Use(middleware.Compress(cfg))
 	}
 
-	req := &Request{
-		Method:  parts[0],
-		Path:    parts[1],
-		Version: parts[2],
-		Query:   make(map[string]string),
-		Headers: make(map[string]string),
+	api := r.Group(cfg.ApiBasePath)
+	api.Handle("GET /users", h.getUsers)
+	api.Handle("GET /users/{id:int}", h.getUser)
+	// JSON пользователя маленький, большой body тут не нужен
+	writes := []*router.Route{
+		api.Handle("POST /users", h.createUser).MaxBody(64 << 10),
+		api.Handle("PUT /users/{id:int}", h.updateUser).MaxBody(64 << 10),
+		api.Handle("DELETE /users/{id:int}", h.deleteUser),
 	}
-
-	// Читаем заголовки
-	contentLength := 0
-	contentType := ""
-	for {
-		hline, err := reader.ReadString('\n')
-		if err != nil {
-			return nil, fmt.Errorf("failed to read header line: %w", err)
-		}
-		hline = strings.TrimRight(hline, "\r\n")
-		if hline == "" {
-			break // конец заголовков
-		}
-
-		parts := strings.SplitN(hline, ":", 2)
-		if len(parts) != 2 {
-			continue
+	if cfg.AuthRequired {
+		for _, route := range writes {
+			route.Guard(h.requireAuth)
 		}
+	}
 
-		name := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		req.Headers[name] = value // <-- сохраняем заголовок
+	r.Handle("GET /ws/users", h.watchUsers).Guard(h.requireSocketAuth)
 
-		if strings.ToLower(name) == "content-length" {
-			if cl, err := strconv.Atoi(value); err == nil {
-				contentLength = cl
-			}
-		}
-		if strings.ToLower(name) == "content-type" {
-			contentType = value
+	// статика регистрируется после API: маршруты проверяются по порядку,
+	// поэтому даже префикс "/" не перекроет GET-маршруты API
+	for _, m := range cfg.StaticMounts {
+		fs, err := static.New(m.Dir, cfg.StaticIndex...)
+		if err != nil {
+			return nil, fmt.Errorf("static %s: %w", m.Prefix, err)
 		}
+		r.Handle("GET "+m.Prefix+"/{"+static.PathParam+"...}", fs.Serve)
 	}
+	return r, nil
+}
 
-	// Читаем тело
-	if contentLength > 0 {
-		body := make([]byte, contentLength)
-		_, err := io.ReadFull(reader, body)
+// GET /users
+func (h *Handler) getUsers(w *httpx.ResponseWriter, req *httpx.Request) {
+	var users []model.User
+	var err error
+
+	role := req.URL.Query.Get("role")
+	if role == "" {
+		users, err = h.store.GetUsers()
 		if err != nil {
-			return nil, fmt.Errorf("failed to read body: %w", err)
+			logger.Log.Error("ошибка получения всех пользователей", "error", err)
+			sendStatus(w, 500)
+			return
 		}
-		req.Body = body
-	}
-
-	// Если multipart/form-data — разбираем файлы
-	if strings.Contains(strings.ToLower(contentType), "multipart/form-data") { //прочитать поподробнее
-		if err := parseMultipart(req, contentType); err != nil {
-			return nil, err
+		logger.Log.Info("получены все пользователи")
+	} else {
+		users, err = h.store.GetUsersByRole(role)
+		if err != nil {
+			logger.Log.Error("ошибка получения пользователей по роли", "role", role, "error", err)
+			sendStatus(w, 500)
+			return
 		}
+		logger.Log.Info("получены пользователи по роли", "role", role)
 	}
-
-	// Парсим query-параметры
-	if idx := strings.Index(req.Path, "?"); idx != -1 {
-		rawQuery := req.Path[idx+1:]
-		req.Path = req.Path[:idx]
-		for _, pair := range strings.Split(rawQuery, "&") {
-			kv := strings.SplitN(pair, "=", 2)
-			if len(kv) == 2 {
-				req.Query[kv[0]] = kv[1]
-			}
-		}
-	} //проверить спец символы
-
-	fmt.Println("\nHeaders:")
-	for k, v := range req.Headers {
-		fmt.Printf("%-20s : %s\n", k, v)
+	// Last-Modified у списка нет: удаление не меняет максимум UpdatedAt
+	if !httpx.CheckPreconditions(w, req, listETag(users), time.Time{}) {
+		return
 	}
+	sendJSON(w, 200, users)
+}
 
-	if len(req.Body) > 0 {
-		fmt.Printf("тело запроса (%d байт):\n%s\n", len(req.Body), string(req.Body))
-	} else {
-		fmt.Println("тело запроса: <пустое>")
+// GET /users/{id}
+func (h *Handler) getUser(w *httpx.ResponseWriter, req *httpx.Request) {
+	id, err := strconv.Atoi(req.Param("id"))
+	if err != nil {
+		sendStatus(w, 400)
+		return
 	}
-	fmt.Println(strings.Repeat("-", 80))
-
-	return req, nil
+	user, ok, err := h.store.GetUser(id)
+	if err != nil {
+		logger.Log.Error("ошибка SQL", "error", err)
+		sendStatus(w, 500)
+		return
+	}
+	if !ok {
+		sendStatus(w, 404)
+		return
+	}
+	if !httpx.CheckPreconditions(w, req, userETag(user), user.UpdatedAt) {
+		return
+	}
+	sendJSON(w, 200, user)
 }
 
-func parseMultipart(req *Request, contentType string) error {
-	boundaryIdx := strings.Index(contentType, "boundary=")
-	if boundaryIdx == -1 {
-		return fmt.Errorf("missing boundary in multipart/form-data")
+// POST /users
+func (h *Handler) createUser(w *httpx.ResponseWriter, req *httpx.Request) {
+	u, ok := decodeUser(req)
+	if !ok {
+		sendStatus(w, 400)
+		return
 	}
-
-	boundary := "--" + contentType[boundaryIdx+9:]
-	parts := strings.Split(string(req.Body), boundary)
-
-	for _, part := range parts {
-		part = strings.Trim(part, "\r\n")
-		if part == "" || part == "--" {
-			continue
-		}
-
-		lines := strings.Split(part, "\n")
-		var filename, partContentType string
-		ctStart := 0
-
-		for i, line := range lines {
-			line = strings.TrimSpace(line)
-			if line == "" {
-				ctStart = i + 1
-				break
-			}
-			if strings.HasPrefix(line, "Content-Disposition:") {
-				if idx := strings.Index(line, "filename="); idx != -1 {
-					filename = strings.Trim(line[idx+9:], "\"")
-					filename = strings.TrimSpace(filename)
-				}
-			}
-			if strings.HasPrefix(strings.ToLower(line), "content-type:") {
-				partContentType = strings.TrimSpace(line[len("Content-Type:"):])
-			}
-		}
-
-		if partContentType == "" {
-			partContentType = "application/octet-stream"
-		}
-
-		fcontent := strings.Join(lines[ctStart:], "\n")
-		uploadF := &UploadReq{
-			Filename:    filename,
-			Size:        len(fcontent),
-			ContentType: partContentType,
-			Content:     fcontent,
-		}
-
-		req.Uploads = append(req.Uploads, uploadF)
+	createdUser, err := h.store.CreateUser(u)
+	if err != nil {
+		logger.Log.Error("ошибка создания пользователя", "error", err)
+		sendStatus(w, 500)
+		return
 	}
-
-	return nil
+	setValidators(w, createdUser)
+	sendJSON(w, 201, createdUser)
 }
 
-// HandleConnection обрабатывает одно TCP соединение
-func HandleConnection(conn net.Conn, store *storage.Storage, cfg *config.Config) {
-	defer conn.Close()
-
-	logger.Log.Info("новое подключение", "address", conn.RemoteAddr())
-	req, err := parseRequest(conn)
+// PUT /users/{id}. С If-Match обновляет, только если клиент видел
+// текущую версию, иначе 412
+func (h *Handler) updateUser(w *httpx.ResponseWriter, req *httpx.Request) {
+	id, err := strconv.Atoi(req.Param("id"))
 	if err != nil {
-		logger.Log.Error("ошибка парсинга запроса", "error", err)
-		sendStatus(conn, 400)
+		sendStatus(w, 400)
 		return
 	}
+	u, ok := decodeUser(req)
+	if !ok {
+		sendStatus(w, 400)
+		return
+	}
+	version, ok := h.checkWrite(w, req, id)
+	if !ok {
+		return
+	}
+	u.ID = id
+	updatedUser, ok, err := h.store.UpdateUser(u, version)
+	if errors.Is(err, storage.ErrVersionMismatch) {
+		// пользователя изменили между проверкой и записью
+		sendStatus(w, 412)
+		return
+	}
+	if err != nil {
+		logger.Log.Error("ошибка обновления пользователя", "id", id, "error", err)
+		sendStatus(w, 500)
+		return
+	}
+	if !ok {
+		sendStatus(w, 404)
+		return
+	}
+	setValidators(w, updatedUser)
+	sendJSON(w, 200, updatedUser)
+}
 
-	logger.Log.Info("получен запрос", "method", req.Method, "path", req.Path, "query", req.Query)
-
-	base := cfg.ApiBasePath
+// DELETE /users/{id}
+func (h *Handler) deleteUser(w *httpx.ResponseWriter, req *httpx.Request) {
+	id, err := strconv.Atoi(req.Param("id"))
+	if err != nil {
+		sendStatus(w, 400)
+		return
+	}
+	version, ok := h.checkWrite(w, req, id)
+	if !ok {
+		return
+	}
+	ok, err = h.store.DeleteUser(id, version)
+	if errors.Is(err, storage.ErrVersionMismatch) {
+		sendStatus(w, 412)
+		return
+	}
+	if err != nil {
+		logger.Log.Error("ошибка удаления пользователя", "id", id, "error", err)
+		sendStatus(w, 500)
+		return
+	}
+	if !ok {
+		sendStatus(w, 404)
+		return
+	}
+	sendStatus(w, 204)
+}
 
-	switch {
-	// GET /users
-	case req.Method == "GET" && req.Path == base+"/users":
-		role := req.Query["role"]
-		fmt.Println("role debug", role)
-		var users []model.User
-		if role == "" {
-			users, err = store.GetUsers()
-			if err != nil {
-				logger.Log.Error("ошибка получения всех пользователей", "error", err)
-				sendStatus(conn, 500)
-				return
-			}
-			logger.Log.Info("получены все пользователи")
+// checkWrite проверяет условные заголовки перед изменением пользователя.
+// Возвращает версию, которую должна застать запись (0 — любую),
+// или false, если ответ уже отправлен
+func (h *Handler) checkWrite(w *httpx.ResponseWriter, req *httpx.Request, id int) (int, bool) {
+	conditional := req.Headers.Has("If-Match") || req.Headers.Has("If-Unmodified-Since") ||
+		req.Headers.Has("If-None-Match")
+	if !conditional {
+		return 0, true
+	}
+	current, ok, err := h.store.GetUser(id)
+	if err != nil {
+		logger.Log.Error("ошибка SQL", "error", err)
+		sendStatus(w, 500)
+		return 0, false
+	}
+	if !ok {
+		// If-Match: * без текущего представления — 412 (RFC 9110, 13.1.1)
+		if req.Headers.Has("If-Match") {
+			sendStatus(w, 412)
 		} else {
-			users, err = store.GetUsersByRole(role)
-			if err != nil {
-				logger.Log.Error("ошибка получения пользователей по роли", "role", role, "error", err)
-				sendStatus(conn, 500)
-				return
-			}
-			logger.Log.Info("получены пользователи по роли", "role", role)
+			sendStatus(w, 404)
 		}
-		sendJSON(conn, 200, users)
-		return
+		return 0, false
+	}
+	if !httpx.CheckPreconditions(w, req, userETag(current), current.UpdatedAt) {
+		return 0, false
+	}
+	return current.Version, true
+}
 
-	// GET /users/{id}
-	case req.Method == "GET" && strings.HasPrefix(req.Path, base+"/users/"):
-		idStr := strings.TrimPrefix(req.Path, base+"/users/")
-		id, err := strconv.Atoi(idStr)
-		if err != nil {
-			sendStatus(conn, 400)
-			return
-		}
-		user, ok, err := store.GetUser(id)
-		if err != nil {
-			logger.Log.Error("ошибка SQL", "error", err)
-			sendStatus(conn, 500)
-			return
-		}
-		if !ok {
-			sendStatus(conn, 404)
-			return
-		}
-		sendJSON(conn, 200, user)
-		return
+// userETag — сильный ETag из id и версии строки
+func userETag(u model.User) string {
+	return fmt.Sprintf(`"%d-%d"`, u.ID, u.Version)
+}
 
-	// POST /users
-	case req.Method == "POST" && req.Path == base+"/users":
-		var u model.User
-		if err := json.Unmarshal(req.Body, &u); err != nil {
-			sendStatus(conn, 400)
-			return
-		}
-		createdUser, err := store.CreateUser(u)
-		if err != nil {
-			logger.Log.Error("ошибка создания пользователя", "error", err)
-			sendStatus(conn, 500)
-			return
-		}
-		sendJSON(conn, 201, createdUser)
-		return
+// listETag меняется при добавлении, изменении и удалении любого
+// пользователя из списка
+func listETag(users []model.User) string {
+	hash := sha256.New()
+	for _, u := range users {
+		fmt.Fprintf(hash, "%d-%d;", u.ID, u.Version)
+	}
+	return fmt.Sprintf(`"%x"`, hash.Sum(nil)[:16])
+}
+
+// setValidators отдаёт ETag и Last-Modified новой версии после записи
+func setValidators(w *httpx.ResponseWriter, u model.User) {
+	w.Header().Set("ETag", userETag(u))
+	w.Header().Set("Last-Modified", httpx.FormatTime(u.UpdatedAt))
+}
+
+// decodeUser читает пользователя из HTML-формы или JSON
+func decodeUser(req *httpx.Request) (model.User, bool) {
+	if req.PostForm != nil || req.FormData != nil {
+		// HTML-форма из админки
+		return model.User{
+			Username: req.FormValue("username"),
+			Role:     req.FormValue("role"),
+			Login:    req.FormValue("login"),
+			Password: req.FormValue("password"),
+		}, true
+	}
+	var u model.User
+	if err := json.Unmarshal(req.Body, &u); err != nil {
+		return model.User{}, false
+	}
+	return u, true
+}
 
-	default:
-		sendStatus(conn, 405)
+// requireAuth пропускает только запросы с валидным JWT или от клиента,
+// опознанного по сертификату. Выполняется до чтения тела
+func (h *Handler) requireAuth(w *httpx.ResponseWriter, req *httpx.Request) bool {
+	if h.peer(req) != nil {
+		return true
 	}
+	if _, err := jwt.ParseToken(h.cfg, req.Headers); err != nil {
+		logger.Log.Warn("отказ в доступе", "path", req.URL.Path, "error", err)
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		sendStatus(w, 401)
+		return false
+	}
+	return true
+}
 
-	logger.Log.Info("обработка запроса завершена", "address", conn.RemoteAddr())
+// peer опознаёт клиента по сертификату, если mTLS включён
+func (h *Handler) peer(req *httpx.Request) *model.Identity {
+	if h.cfg.TLSClientAuth == "off" {
+		return nil
+	}
+	return middleware.IdentifyPeer(h.cfg, h.store.GetUser, req)
 }
 
 // sendJSON отправляет JSON с указанным статусом
-func sendJSON(conn net.Conn, status int, data interface{}) {
-	body, _ := json.Marshal(data)
-	resp := fmt.Sprintf(
-		"HTTP/1.1 %d OK\r\nContent-Type: application/json; charset=utf-8\r\nContent-Length: %d\r\n\r\n%s",
-		status, len(body), string(body),
-	)
-	conn.Write([]byte(resp))
+func sendJSON(w *httpx.ResponseWriter, status int, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		logger.Log.Error("ошибка сериализации JSON", "error", err)
+		sendStatus(w, 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body)
 
 	logger.Log.Debug("отправлен JSON-ответ",
 		"status", status,
 		"length", len(body),
-		"address", conn.RemoteAddr(),
 	)
 }
 
 // sendStatus отправляет пустой ответ с кодом состояния
-func sendStatus(conn net.Conn, status int) {
-	statusText := map[int]string{
-		200: "OK",
-		201: "Created",
-		204: "No Content",
-		400: "Bad Request",
-		404: "Not Found",
-		405: "Method Not Allowed",
-	}[status]
-	if statusText == "" {
-		statusText = "Unknown"
-	}
-	resp := fmt.Sprintf("HTTP/1.1 %d %s\r\nContent-Length: 0\r\nConnection: close\r\n\r\n", status, statusText)
-	_, _ = conn.Write([]byte(resp))
+func sendStatus(w *httpx.ResponseWriter, status int) {
+	w.WriteHeader(status)
 }
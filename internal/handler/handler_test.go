@@ -0,0 +1,437 @@
+package handler_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"web-server/internal/config"
+	"web-server/internal/model"
+	"web-server/internal/servertest"
+	"web-server/pkg/jwt"
+)
+
+const usersPath = "/api/v1/users"
+
+// TestUsersAPI повторяет сценарий test_api.sh
+func TestUsersAPI(t *testing.T) {
+	s := servertest.New(t)
+	s.JSON(t, "POST", usersPath, model.User{Username: "Admin", Role: "admin", Login: "admin"}).AssertStatus(t, 201)
+
+	// GET all users
+	var users []model.User
+	s.Do(t, "GET", usersPath, nil, "Accept", "application/json").
+		AssertStatus(t, 200).
+		AssertHeader(t, "Content-Type", "application/json; charset=utf-8").
+		Decode(t, &users)
+	if len(users) != 1 {
+		t.Fatalf("users = %+v, want one admin", users)
+	}
+
+	// GET users with role=admin
+	s.Do(t, "GET", usersPath+"?role=admin", nil).AssertStatus(t, 200).Decode(t, &users)
+	if len(users) != 1 || users[0].Role != "admin" {
+		t.Fatalf("admins = %+v", users)
+	}
+	s.Do(t, "GET", usersPath+"?role=nobody", nil).AssertStatus(t, 200).Decode(t, &users)
+	if len(users) != 0 {
+		t.Fatalf("role=nobody returned %+v", users)
+	}
+
+	// POST new user
+	var created model.User
+	s.JSON(t, "POST", usersPath, map[string]string{"username": "TestUser", "role": "user"}).
+		AssertStatus(t, 201).Decode(t, &created)
+	if created.ID == 0 || created.Username != "TestUser" || created.Role != "user" {
+		t.Fatalf("created = %+v", created)
+	}
+	userPath := fmt.Sprintf("%s/%d", usersPath, created.ID)
+
+	// GET user by ID
+	var got model.User
+	s.Do(t, "GET", userPath, nil).AssertStatus(t, 200).Decode(t, &got)
+	if got != created {
+		t.Fatalf("got %+v, want %+v", got, created)
+	}
+
+	// PUT update user
+	var updated model.User
+	s.JSON(t, "PUT", userPath, map[string]string{"username": "UpdatedUser", "role": "user"}).
+		AssertStatus(t, 200).Decode(t, &updated)
+	if updated.ID != created.ID || updated.Username != "UpdatedUser" {
+		t.Fatalf("updated = %+v", updated)
+	}
+
+	// GET updated user
+	s.Do(t, "GET", userPath, nil).AssertStatus(t, 200).Decode(t, &got)
+	if got.Username != "UpdatedUser" {
+		t.Fatalf("after update got %+v", got)
+	}
+
+	// DELETE user
+	s.Do(t, "DELETE", userPath, nil).AssertStatus(t, 204)
+
+	// GET deleted user (should 404)
+	s.Do(t, "GET", userPath, nil).AssertStatus(t, 404)
+	s.Do(t, "DELETE", userPath, nil).AssertStatus(t, 404)
+	s.JSON(t, "PUT", userPath, map[string]string{"username": "Ghost"}).AssertStatus(t, 404)
+
+	// GET all users after deletion
+	s.Do(t, "GET", usersPath, nil).AssertStatus(t, 200).Decode(t, &users)
+	if len(users) != 1 || users[0].Username != "Admin" {
+		t.Fatalf("after delete users = %+v", users)
+	}
+}
+
+func TestUsersAPIErrors(t *testing.T) {
+	s := servertest.New(t)
+
+	s.Do(t, "GET", usersPath+"/abc", nil).AssertStatus(t, 404)
+	s.Do(t, "POST", usersPath, []byte("{broken"), "Content-Type", "application/json").AssertStatus(t, 400)
+	s.Do(t, "PATCH", usersPath, []byte("{}")).AssertStatus(t, 405).
+		AssertHeader(t, "Allow", "GET, HEAD, OPTIONS, POST")
+	s.Do(t, "POST", usersPath, make([]byte, 65<<10)).AssertStatus(t, 413)
+}
+
+func TestCreateUserForm(t *testing.T) {
+	s := servertest.New(t)
+
+	var created model.User
+	s.Do(t, "POST", usersPath, []byte("username=Form+User&role=user&login=form&password=p%40ss"),
+		"Content-Type", "application/x-www-form-urlencoded").
+		AssertStatus(t, 201).Decode(t, &created)
+	if created.Username != "Form User" || created.Login != "form" {
+		t.Fatalf("created = %+v", created)
+	}
+}
+
+func TestAuthRequired(t *testing.T) {
+	s := servertest.New(t, func(cfg *config.Config) { cfg.AuthRequired = true })
+	user := map[string]string{"username": "u", "role": "user", "login": "u"}
+
+	s.JSON(t, "POST", usersPath, user).
+		AssertStatus(t, 401).
+		AssertHeader(t, "WWW-Authenticate", "Bearer")
+	s.JSON(t, "POST", usersPath, user, "Authorization", "Bearer garbage").AssertStatus(t, 401)
+	s.Do(t, "DELETE", usersPath+"/1", nil).AssertStatus(t, 401)
+
+	auth := "Bearer " + s.Token(t, 1)
+	s.JSON(t, "POST", usersPath, user, "Authorization", auth).AssertStatus(t, 201)
+	s.Do(t, "DELETE", usersPath+"/1", nil, "Authorization", auth).AssertStatus(t, 204)
+	// чтение открыто и без токена
+	s.Do(t, "GET", usersPath, nil).AssertStatus(t, 200)
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := servertest.NewCA(t)
+	s := servertest.New(t, func(cfg *config.Config) {
+		cfg.AuthRequired = true
+		cfg.TLSCerts = []config.TLSCert{servertest.WriteCert(t, "127.0.0.1")}
+		cfg.TLSClientAuth = "request"
+		cfg.TLSClientCA = ca.File
+		cfg.TLSClientIdentities = []config.ClientIdentity{
+			{Match: "cn:alice", UserID: 1},
+			{Match: "uri:spiffe://corp/billing", Service: "billing"},
+			{Match: "cn:ghost", UserID: 99},
+		}
+	})
+	if _, err := s.Store.CreateUser(model.User{Username: "alice", Role: "admin", Login: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	// login уникален, поэтому каждому создаваемому пользователю свой
+	n := 0
+	user := func() map[string]string {
+		n++
+		return map[string]string{"username": "u", "role": "user", "login": fmt.Sprint("u", n)}
+	}
+
+	// сертификат, сопоставленный пользователю или сервису, заменяет JWT
+	alice := s.WithClientCert(ca.Issue(t, x509.Certificate{Subject: pkix.Name{CommonName: "alice"}}))
+	alice.JSON(t, "POST", usersPath, user()).AssertStatus(t, 201)
+	billing := s.WithClientCert(ca.Issue(t, x509.Certificate{
+		Subject: pkix.Name{CommonName: "billing"},
+		URIs:    []*url.URL{{Scheme: "spiffe", Host: "corp", Path: "/billing"}},
+	}))
+	billing.Do(t, "DELETE", usersPath+"/2", nil).AssertStatus(t, 204)
+
+	// проверенный, но не сопоставленный сертификат — как его отсутствие
+	for _, cn := range []string{"mallory", "ghost"} {
+		c := s.WithClientCert(ca.Issue(t, x509.Certificate{Subject: pkix.Name{CommonName: cn}}))
+		c.JSON(t, "POST", usersPath, user()).AssertStatus(t, 401)
+		c.JSON(t, "POST", usersPath, user(), "Authorization", "Bearer "+c.Token(t, 1)).AssertStatus(t, 201)
+	}
+	// в режиме request без сертификата остаётся JWT
+	s.JSON(t, "POST", usersPath, user()).AssertStatus(t, 401)
+	s.JSON(t, "POST", usersPath, user(), "Authorization", "Bearer "+s.Token(t, 1)).AssertStatus(t, 201)
+
+	// сертификат чужого CA не проходит рукопожатие
+	stranger := servertest.NewCA(t).Issue(t, x509.Certificate{Subject: pkix.Name{CommonName: "alice"}})
+	if reply, err := rawTLS(s.Addr, &stranger); err == nil {
+		t.Fatalf("foreign CA accepted: %q", reply)
+	}
+}
+
+func TestMutualTLSRequire(t *testing.T) {
+	ca := servertest.NewCA(t)
+	s := servertest.New(t, func(cfg *config.Config) {
+		cfg.TLSCerts = []config.TLSCert{servertest.WriteCert(t, "127.0.0.1")}
+		cfg.TLSClientAuth = "require"
+		cfg.TLSClientCA = ca.File
+	})
+
+	if reply, err := rawTLS(s.Addr, nil); err == nil {
+		t.Fatalf("handshake without certificate accepted: %q", reply)
+	}
+	cert := ca.Issue(t, x509.Certificate{Subject: pkix.Name{CommonName: "anyone"}})
+	s.WithClientCert(cert).Do(t, "GET", usersPath, nil).AssertStatus(t, 200)
+}
+
+// rawTLS отправляет GET по TLS с клиентским сертификатом cert. В TLS 1.3
+// сервер отвергает сертификат уже после рукопожатия клиента, поэтому
+// ошибка может прийти только при чтении ответа
+func rawTLS(addr string, cert *tls.Certificate) ([]byte, error) {
+	config := &tls.Config{InsecureSkipVerify: true}
+	if cert != nil {
+		config.Certificates = []tls.Certificate{*cert}
+	}
+	conn, err := tls.Dial("tcp4", addr, config)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(conn, "GET "+usersPath+" HTTP/1.1\r\nHost: x\r\nConnection: close\r\n\r\n"); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(conn)
+}
+
+func TestUsersCompressed(t *testing.T) {
+	s := servertest.New(t, func(cfg *config.Config) { cfg.CompressMinBytes = 0 })
+	s.JSON(t, "POST", usersPath, model.User{Username: "Admin", Role: "admin", Login: "admin"}).AssertStatus(t, 201)
+
+	resp := s.Do(t, "GET", usersPath, nil, "Accept-Encoding", "gzip;q=0.5, deflate;q=0.1").
+		AssertStatus(t, 200).
+		AssertHeader(t, "Content-Encoding", "gzip").
+		AssertHeader(t, "Vary", "Accept-Encoding")
+	zr, err := gzip.NewReader(bytes.NewReader(resp.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users []model.User
+	if err := json.NewDecoder(zr).Decode(&users); err != nil || len(users) != 1 {
+		t.Fatalf("users = %+v, err = %v", users, err)
+	}
+
+	s.Do(t, "GET", usersPath, nil).
+		AssertStatus(t, 200).
+		AssertHeader(t, "Content-Encoding", "").
+		AssertHeader(t, "Vary", "Accept-Encoding")
+}
+
+func TestUserETags(t *testing.T) {
+	s := servertest.New(t, func(cfg *config.Config) { cfg.CompressMinBytes = 0 })
+	var u model.User
+	s.JSON(t, "POST", usersPath, model.User{Username: "A", Role: "user", Login: "a"}).
+		AssertStatus(t, 201).
+		AssertHeader(t, "ETag", fmt.Sprintf(`"%d-1"`, 1)).
+		Decode(t, &u)
+	userPath := fmt.Sprintf("%s/%d", usersPath, u.ID)
+
+	// чтение: If-None-Match и If-Modified-Since
+	get := s.Do(t, "GET", userPath, nil).AssertStatus(t, 200).AssertHeader(t, "ETag", `"1-1"`)
+	lastModified := get.Header.Get("Last-Modified")
+	if lastModified == "" {
+		t.Fatal("no Last-Modified")
+	}
+	s.Do(t, "GET", userPath, nil, "If-None-Match", `"1-1"`).AssertStatus(t, 304).AssertHeader(t, "ETag", `"1-1"`)
+	s.Do(t, "GET", userPath, nil, "If-Modified-Since", lastModified).AssertStatus(t, 304)
+	list := s.Do(t, "GET", usersPath, nil).AssertStatus(t, 200)
+	s.Do(t, "GET", usersPath, nil, "If-None-Match", list.Header.Get("ETag")).AssertStatus(t, 304)
+
+	// запись: устаревшая версия — 412, текущая — успех с новым ETag
+	update := map[string]string{"username": "B", "role": "user"}
+	s.JSON(t, "PUT", userPath, update, "If-Match", `"1-0"`).AssertStatus(t, 412)
+	s.JSON(t, "PUT", userPath, update, "If-Match", `"1-1"`).AssertStatus(t, 200).AssertHeader(t, "ETag", `"1-2"`)
+	s.JSON(t, "PUT", userPath, update, "If-Match", `"1-1"`).AssertStatus(t, 412)
+	s.Do(t, "GET", userPath, nil, "If-None-Match", `"1-1"`).AssertStatus(t, 200)
+	s.Do(t, "GET", usersPath, nil, "If-None-Match", list.Header.Get("ETag")).AssertStatus(t, 200)
+
+	// ETag сжатого ответа тоже годится для If-Match
+	gz := s.Do(t, "GET", userPath, nil, "Accept-Encoding", "gzip").AssertHeader(t, "ETag", `"1-2-gzip"`)
+	s.Do(t, "GET", userPath, nil, "Accept-Encoding", "gzip", "If-None-Match", gz.Header.Get("ETag")).
+		AssertStatus(t, 304).
+		AssertHeader(t, "ETag", `"1-2-gzip"`).
+		AssertHeader(t, "Vary", "Accept-Encoding")
+	s.Do(t, "DELETE", userPath, nil, "If-Match", `"1-1"`).AssertStatus(t, 412)
+	s.Do(t, "DELETE", userPath, nil, "If-Match", gz.Header.Get("ETag")).AssertStatus(t, 204)
+	s.Do(t, "DELETE", userPath, nil, "If-Match", "*").AssertStatus(t, 412)
+}
+
+func TestWatchUsers(t *testing.T) {
+	s := servertest.New(t)
+	token := s.Token(t, 1)
+
+	// без токена — 401 ещё до рукопожатия
+	ws, resp := s.WebSocket(t, "/ws/users")
+	if ws != nil || resp.Status != 401 {
+		t.Fatalf("no token: status %d", resp.Status)
+	}
+	// токен из подпротокола, как его передаёт браузер
+	ws, resp = s.WebSocket(t, "/ws/users", "Sec-WebSocket-Protocol", "bearer, "+token)
+	if ws == nil {
+		t.Fatalf("handshake: status %d %q", resp.Status, resp.Body)
+	}
+	resp.AssertHeader(t, "Sec-WebSocket-Protocol", "bearer")
+
+	auth := "Bearer " + token
+	var created model.User
+	s.JSON(t, "POST", usersPath, map[string]string{"username": "live", "role": "user", "login": "live"}, "Authorization", auth).
+		AssertStatus(t, 201).Decode(t, &created)
+	userPath := fmt.Sprintf("%s/%d", usersPath, created.ID)
+	s.JSON(t, "PUT", userPath, map[string]string{"username": "renamed", "role": "admin"}, "Authorization", auth).AssertStatus(t, 200)
+	s.Do(t, "DELETE", userPath, nil, "Authorization", auth).AssertStatus(t, 204)
+
+	for _, want := range []struct {
+		typ, username string
+	}{{"created", "live"}, {"updated", "renamed"}, {"deleted", ""}} {
+		opcode, data := ws.ReadMessage(t)
+		var ev struct {
+			Type string      `json:"type"`
+			ID   int         `json:"id"`
+			User *model.User `json:"user"`
+		}
+		if err := json.Unmarshal(data, &ev); err != nil || opcode != 0x1 {
+			t.Fatalf("event %q (opcode %d): %v", data, opcode, err)
+		}
+		if ev.Type != want.typ || ev.ID != created.ID {
+			t.Errorf("event = %s, want %s for id %d", data, want.typ, created.ID)
+		}
+		if (ev.User == nil) != (want.username == "") || ev.User != nil && ev.User.Username != want.username {
+			t.Errorf("event user = %s", data)
+		}
+		if bytes.Contains(data, []byte("password")) {
+			t.Errorf("event leaks password: %s", data)
+		}
+	}
+
+	// ping от клиента получает pong с тем же телом
+	ws.WriteFrame(t, true, 0x9, []byte("hi"), true)
+	if _, op, payload := ws.ReadFrame(t); op != 0xA || string(payload) != "hi" {
+		t.Errorf("ping reply = %#x %q", op, payload)
+	}
+
+	// закрытие клиентом: сервер отвечает тем же кодом и закрывает TCP
+	ws.WriteClose(t, 1000, "bye")
+	if code, _ := ws.ReadClose(t); code != 1000 {
+		t.Errorf("close reply code = %d", code)
+	}
+	if !ws.Closed() {
+		t.Error("connection still open after close handshake")
+	}
+}
+
+func TestWatchUsersProtocolErrors(t *testing.T) {
+	s := servertest.New(t, func(cfg *config.Config) { cfg.WSMaxMessageBytes = 1024 })
+	auth := "Bearer " + s.Token(t, 1)
+
+	tests := []struct {
+		name string
+		send func(ws *servertest.WebSocket)
+		code int
+	}{
+		{"unmasked", func(ws *servertest.WebSocket) { ws.WriteFrame(t, true, 0x1, []byte("x"), false) }, 1002},
+		{"unknown opcode", func(ws *servertest.WebSocket) { ws.WriteFrame(t, true, 0x3, nil, true) }, 1002},
+		{"fragmented ping", func(ws *servertest.WebSocket) { ws.WriteFrame(t, false, 0x9, nil, true) }, 1002},
+		{"continuation first", func(ws *servertest.WebSocket) { ws.WriteFrame(t, true, 0x0, []byte("x"), true) }, 1002},
+		{"interleaved message", func(ws *servertest.WebSocket) {
+			ws.WriteFrame(t, false, 0x1, []byte("a"), true)
+			ws.WriteFrame(t, true, 0x1, []byte("b"), true)
+		}, 1002},
+		{"too big", func(ws *servertest.WebSocket) { ws.WriteFrame(t, true, 0x2, make([]byte, 2000), true) }, 1009},
+		{"too big fragmented", func(ws *servertest.WebSocket) {
+			ws.WriteFrame(t, false, 0x2, make([]byte, 800), true)
+			ws.WriteFrame(t, true, 0x0, make([]byte, 800), true)
+		}, 1009},
+		// "é" разрезан между фрагментами — валиден только целиком
+		{"utf-8 split ok, then invalid", func(ws *servertest.WebSocket) {
+			ws.WriteFrame(t, false, 0x1, []byte{0xc3}, true)
+			ws.WriteFrame(t, true, 0x0, []byte{0xa9}, true)
+			ws.WriteFrame(t, true, 0x1, []byte{0xff}, true)
+		}, 1007},
+		{"reserved close code", func(ws *servertest.WebSocket) { ws.WriteClose(t, 1005, "") }, 1002},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, resp := s.WebSocket(t, "/ws/users", "Authorization", auth)
+			if ws == nil {
+				t.Fatalf("handshake: status %d", resp.Status)
+			}
+			tt.send(ws)
+			if code, reason := ws.ReadClose(t); code != tt.code {
+				t.Errorf("close = %d %q, want %d", code, reason, tt.code)
+			}
+		})
+	}
+}
+
+func TestWatchUsersHandshake(t *testing.T) {
+	s := servertest.New(t)
+	auth := "Bearer " + s.Token(t, 1)
+
+	_, resp := s.WebSocket(t, "/ws/users", "Authorization", auth, "Sec-WebSocket-Version", "8")
+	resp.AssertStatus(t, 426).AssertHeader(t, "Sec-WebSocket-Version", "13")
+	_, resp = s.WebSocket(t, "/ws/users", "Authorization", auth, "Upgrade", "")
+	resp.AssertStatus(t, 426).AssertHeader(t, "Upgrade", "websocket")
+	_, resp = s.WebSocket(t, "/ws/users", "Authorization", auth, "Sec-WebSocket-Key", "c2hvcnQ=")
+	resp.AssertStatus(t, 400)
+	_, resp = s.WebSocket(t, "/ws/users", "Authorization", auth, "Origin", "https://evil.test")
+	resp.AssertStatus(t, 403)
+	_, resp = s.WebSocket(t, "/ws/users", "Authorization", auth, "Origin", "http://"+s.Addr)
+	resp.AssertStatus(t, 101).AssertHeader(t, "Connection", "Upgrade")
+
+	// ключ из RFC 6455, 1.3
+	_, resp = s.WebSocket(t, "/ws/users", "Authorization", auth, "Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	resp.AssertStatus(t, 101).AssertHeader(t, "Sec-WebSocket-Accept", "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+
+	// истёкший токен отвергается ещё на рукопожатии
+	cfg := *s.Cfg
+	cfg.JwtExpires = -1
+	expired, err := jwt.GenerateToken(1, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, resp = s.WebSocket(t, "/ws/users", "Authorization", "Bearer "+expired)
+	resp.AssertStatus(t, 401)
+}
+
+func TestWatchUsersShutdown(t *testing.T) {
+	s := servertest.New(t)
+	ws, resp := s.WebSocket(t, "/ws/users", "Authorization", "Bearer "+s.Token(t, 1))
+	if ws == nil {
+		t.Fatalf("handshake: status %d", resp.Status)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.Close(t)
+		close(done)
+	}()
+	if code, _ := ws.ReadClose(t); code != 1001 {
+		t.Errorf("close on shutdown = %d, want 1001", code)
+	}
+	ws.WriteClose(t, 1001, "")
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("shutdown waits for websocket")
+	}
+}
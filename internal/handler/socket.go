@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+	"web-server/internal/httpx"
+	"web-server/internal/websocket"
+
+	"web-server/pkg/jwt"
+	"web-server/pkg/logger"
+)
+
+// bearerProtocol — подпротокол для передачи JWT из браузера: там у
+// WebSocket нельзя задать Authorization, а в query токен попал бы в лог.
+// Клиент шлёт "Sec-WebSocket-Protocol: bearer, <token>", сервер выбирает bearer
+const bearerProtocol = "bearer"
+
+// closeWait — сколько ждать ответный close после нашего
+const closeWait = 5 * time.Second
+
+// requireSocketAuth — requireAuth для WebSocket: токен может прийти
+// и в подпротоколе
+func (h *Handler) requireSocketAuth(w *httpx.ResponseWriter, req *httpx.Request) bool {
+	if h.peer(req) != nil {
+		return true
+	}
+	if _, err := h.socketClaims(req); err != nil {
+		logger.Log.Warn("отказ в доступе", "path", req.URL.Path, "error", err)
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		sendStatus(w, 401)
+		return false
+	}
+	return true
+}
+
+// socketClaims проверяет JWT из Authorization или из подпротокола
+func (h *Handler) socketClaims(req *httpx.Request) (*jwt.Claims, error) {
+	if req.Headers.Has("Authorization") {
+		return jwt.ParseToken(h.cfg, req.Headers)
+	}
+	protocols := websocket.Subprotocols(req)
+	for i, p := range protocols {
+		if p == bearerProtocol && i+1 < len(protocols) {
+			return jwt.VerifyToken(h.cfg, protocols[i+1])
+		}
+	}
+	return nil, errors.New("no bearer token")
+}
+
+// GET /ws/users — события создания, изменения и удаления пользователей.
+// Каждое событие — текстовое сообщение с JSON storage.UserEvent.
+// Соединение закрывается при остановке сервера (1001), по истечении
+// токена (1008) и если клиент не успевает читать события (1008) —
+// тогда ему нужно перечитать GET /users и подключиться снова
+func (h *Handler) watchUsers(w *httpx.ResponseWriter, req *httpx.Request) {
+	// срок есть только у JWT, у клиента с сертификатом его нет
+	var expired <-chan time.Time
+	if req.Peer == nil {
+		claims, err := h.socketClaims(req)
+		if err != nil {
+			sendStatus(w, 401)
+			return
+		}
+		if claims.ExpiresAt != nil {
+			timer := time.NewTimer(time.Until(claims.ExpiresAt.Time))
+			defer timer.Stop()
+			expired = timer.C
+		}
+	}
+
+	// подписка до рукопожатия — события между ними не потеряются
+	events, unsubscribe := h.store.Subscribe()
+	defer unsubscribe()
+
+	conn, err := websocket.Upgrade(w, req, websocket.Options{
+		Subprotocols:    []string{bearerProtocol},
+		MaxMessageBytes: h.cfg.WSMaxMessageBytes,
+		ReadTimeout:     2 * h.cfg.WSPingInterval,
+		WriteTimeout:    h.cfg.WriteTimeout,
+	})
+	if err != nil {
+		logger.Log.Warn("отклонено рукопожатие WebSocket", "address", req.RemoteAddr, "error", err)
+		return
+	}
+	defer conn.Close()
+	logger.Log.Info("подключён слушатель событий пользователей", "address", req.RemoteAddr)
+
+	// клиенту писать нечего, но читать нужно: так приходят pong и close
+	readDone := make(chan error, 1)
+	go func() {
+		defer func() {
+			// ServeConn ловит паники только своей горутины — без recover
+			// паника здесь уронила бы весь сервер
+			if rec := recover(); rec != nil {
+				httpx.RecoverPanic(nil, req, rec)
+				conn.Close()
+				readDone <- fmt.Errorf("websocket reader panic: %v", rec)
+			}
+		}()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				readDone <- err
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(h.cfg.WSPingInterval)
+	defer ping.Stop()
+	for {
+		var code int
+		var reason string
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				// break выходит из select к закрытию ниже
+				code, reason = websocket.ClosePolicyViolation, "too slow, resubscribe"
+				break
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				logger.Log.Error("ошибка сериализации события", "error", err)
+				continue
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				logger.Log.Debug("ошибка отправки события", "address", req.RemoteAddr, "error", err)
+				return
+			}
+			continue
+		case <-ping.C:
+			if err := conn.Ping(nil); err != nil {
+				return
+			}
+			continue
+		case err := <-readDone:
+			logSocketEnd(req, err)
+			return
+		case <-req.Context().Done():
+			code, reason = websocket.CloseGoingAway, "server shutting down"
+		case <-expired:
+			code, reason = websocket.ClosePolicyViolation, "token expired"
+		}
+
+		// закрываем сами и ждём ответный close, но недолго
+		if err := conn.WriteClose(code, reason); err != nil {
+			return
+		}
+		select {
+		case err := <-readDone:
+			logSocketEnd(req, err)
+		case <-time.After(closeWait):
+		}
+		return
+	}
+}
+
+func logSocketEnd(req *httpx.Request, err error) {
+	var ce *websocket.CloseError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &ce):
+		logger.Log.Info("WebSocket закрыт", "address", req.RemoteAddr, "code", ce.Code, "reason", ce.Reason)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		logger.Log.Info("клиент WebSocket не отвечает на ping", "address", req.RemoteAddr)
+	default:
+		logger.Log.Debug("WebSocket оборван", "address", req.RemoteAddr, "error", err)
+	}
+}
@@ -0,0 +1,190 @@
+package httpx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxChunkLineLen = 4096     // строка с размером чанка и расширениями
+	maxTrailerBytes = 8 * 1024 // все трейлеры вместе
+)
+
+// трейлеры, которые нельзя передавать после тела (RFC 9110, 6.5.1)
+var forbiddenTrailers = map[string]bool{
+	"content-length":    true,
+	"transfer-encoding": true,
+	"content-type":      true,
+	"content-encoding":  true,
+	"host":              true,
+	"authorization":     true,
+	"trailer":           true,
+	"connection":        true,
+}
+
+// checkTransferEncoding проверяет заголовок Transfer-Encoding.
+// Поддерживается только chunked, и он должен быть последним кодированием
+func checkTransferEncoding(te string) error {
+	codings := strings.Split(te, ",")
+	last := strings.ToLower(strings.TrimSpace(codings[len(codings)-1]))
+	if last != "chunked" {
+		// длину тела определить нельзя (RFC 9112, 6.3)
+		return newHTTPError(400, "transfer-encoding without final chunked: %q", te)
+	}
+	if len(codings) > 1 {
+		return newHTTPError(501, "unsupported transfer-encoding: %q", te)
+	}
+	return nil
+}
+
+// chunkedReader декодирует тело в формате Transfer-Encoding: chunked
+// по мере чтения. После последнего чанка читает трейлеры в req.Trailers.
+// Тело больше limit байт — 413
+type chunkedReader struct {
+	r      *bufio.Reader
+	req    *Request
+	limit  int64
+	strict bool // строки только с CRLF
+	total  int64
+	left   int64 // непрочитанные байты текущего чанка
+	crlf   bool  // перед следующим размером ждём CRLF от прошлого чанка
+	err    error
+}
+
+func newChunkedReader(r *bufio.Reader, req *Request, limit int64, strict bool) *chunkedReader {
+	return &chunkedReader{r: r, req: req, limit: limit, strict: strict}
+}
+
+func (cr *chunkedReader) Read(p []byte) (int, error) {
+	if cr.err != nil {
+		return 0, cr.err
+	}
+	if cr.left == 0 {
+		if cr.err = cr.nextChunk(); cr.err != nil {
+			return 0, cr.err
+		}
+	}
+
+	if int64(len(p)) > cr.left {
+		p = p[:cr.left]
+	}
+	n, err := cr.r.Read(p)
+	cr.left -= int64(n)
+	cr.total += int64(n)
+	if cr.left == 0 {
+		cr.crlf = true
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		cr.err = fmt.Errorf("failed to read chunk: %w", err)
+	}
+	return n, cr.err
+}
+
+// nextChunk читает строку размера следующего чанка.
+// На последнем (нулевом) чанке дочитывает трейлеры и возвращает io.EOF
+func (cr *chunkedReader) nextChunk() error {
+	if cr.crlf {
+		// после данных чанка обязательно идёт CRLF
+		crlf, err := readLine(cr.r, 2, cr.strict)
+		if err != nil {
+			return chunkedReadError(err)
+		}
+		if crlf != "" {
+			return newHTTPError(400, "missing CRLF after chunk data")
+		}
+		cr.crlf = false
+	}
+
+	line, err := readLine(cr.r, maxChunkLineLen, cr.strict)
+	if err != nil {
+		return chunkedReadError(err)
+	}
+	size, err := parseChunkSize(line)
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		if err := readTrailers(cr.r, cr.req, cr.strict); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	if cr.total+size > cr.limit {
+		return newHTTPError(413, "chunked body exceeds %d bytes", cr.limit)
+	}
+	cr.left = size
+	return nil
+}
+
+// parseChunkSize разбирает строку "size[;ext[=val]]..."
+func parseChunkSize(line string) (int64, error) {
+	sizeStr := line
+	if idx := strings.IndexByte(line, ';'); idx != -1 {
+		sizeStr = line[:idx]
+		// расширения чанков нам не нужны, но имя расширения должно быть
+		for _, ext := range strings.Split(line[idx+1:], ";") {
+			name, _, _ := strings.Cut(ext, "=")
+			if strings.TrimSpace(name) == "" {
+				return 0, newHTTPError(400, "invalid chunk extension: %q", line)
+			}
+		}
+	}
+	sizeStr = strings.TrimRight(sizeStr, " \t")
+	if sizeStr == "" || len(sizeStr) > 15 {
+		return 0, newHTTPError(400, "invalid chunk size: %q", line)
+	}
+	size, err := strconv.ParseInt(sizeStr, 16, 64)
+	if err != nil || size < 0 {
+		return 0, newHTTPError(400, "invalid chunk size: %q", line)
+	}
+	return size, nil
+}
+
+// readTrailers читает поля после последнего чанка до пустой строки
+func readTrailers(reader *bufio.Reader, req *Request, strict bool) error {
+	total := 0
+	for {
+		line, err := readLine(reader, maxTrailerBytes, strict)
+		if err != nil {
+			return chunkedReadError(err)
+		}
+		if line == "" {
+			return nil
+		}
+		total += len(line)
+		if total > maxTrailerBytes {
+			return newHTTPError(400, "trailers exceed %d bytes", maxTrailerBytes)
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || !validHeaderName(name) {
+			return newHTTPError(400, "invalid trailer line: %q", line)
+		}
+		if forbiddenTrailers[strings.ToLower(name)] {
+			continue
+		}
+		if req.Trailers == nil {
+			req.Trailers = make(Header)
+		}
+		req.Trailers.Add(name, strings.TrimSpace(value))
+	}
+}
+
+func chunkedReadError(err error) error {
+	if err == errLineTooLong {
+		return newHTTPError(400, "chunked framing line too long")
+	}
+	if err == errBareLF {
+		return newHTTPError(400, "chunked framing line without CRLF")
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("failed to read chunked body: %w", err)
+}
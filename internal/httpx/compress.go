@@ -0,0 +1,179 @@
+package httpx
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compression — параметры сжатия ответа, выбранные по Accept-Encoding
+type compression struct {
+	encoding string // "gzip", "deflate" или "" — клиент сжатие не принимает
+	minSize  int64
+	types    []string
+}
+
+// encoder — компрессор тела ответа
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// EnableCompression разрешает сжать тело ответа кодировкой encoding, если
+// Content-Type входит в types ("text/" — любой text/*), а тело не меньше
+// minSize байт. Решение принимается при отправке заголовков; потоковые
+// ответы сжимаются всегда. encoding == "" только добавит Vary
+func (rw *ResponseWriter) EnableCompression(encoding string, minSize int64, types []string) {
+	rw.compress = &compression{encoding: encoding, minSize: minSize, types: types}
+}
+
+// startCompression решает, сжимать ли тело размером size (-1 — потоковое),
+// и выставляет заголовки. Вызывается перед writeHeader. HEAD получает
+// те же заголовки, что и GET: тело не отправляется, но решение то же
+func (rw *ResponseWriter) startCompression(size int64) bool {
+	c := rw.compress
+	if c != nil && rw.status == 304 {
+		// 304 повторяет заголовки, с которыми ушёл бы 200
+		rw.addVary()
+	}
+	if c == nil || !bodyAllowed(rw.status) {
+		return false
+	}
+	// уже сжатое (например, .gz с диска) и диапазоны байт не трогаем
+	if rw.header.Has("Content-Encoding") || rw.header.Has("Content-Range") {
+		return false
+	}
+	if !c.allowed(rw.header.Get("Content-Type")) {
+		return false
+	}
+	// представление зависит от Accept-Encoding, даже если сейчас не сжимаем
+	rw.addVary()
+	if c.encoding == "" || (size >= 0 && size < c.minSize) {
+		return false
+	}
+	rw.header.Set("Content-Encoding", c.encoding)
+	rw.header.Del("Content-Length")
+	if etag := rw.header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		rw.header.Set("ETag", compressedETag(etag, c.encoding))
+	}
+	return true
+}
+
+func (rw *ResponseWriter) addVary() {
+	if !hasToken(rw.header.Values("Vary"), "Accept-Encoding") {
+		rw.header.Add("Vary", "Accept-Encoding")
+	}
+}
+
+func (c *compression) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range c.types {
+		if strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t) || mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// bodySink передаёт сжатые данные в тело ответа
+type bodySink struct{ rw *ResponseWriter }
+
+func (s bodySink) Write(p []byte) (int, error) {
+	if err := s.rw.writeBody(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// компрессоры большие (сотни КБ), поэтому переиспользуются
+var (
+	gzipPool = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+	zlibPool = sync.Pool{New: func() any { return zlib.NewWriter(nil) }}
+)
+
+// pooledEncoder возвращает компрессор в пул при Close
+type pooledEncoder struct {
+	encoder
+	pool *sync.Pool
+}
+
+func (e *pooledEncoder) Close() error {
+	err := e.encoder.Close()
+	e.pool.Put(e.encoder)
+	e.encoder = nil
+	return err
+}
+
+// newEncoder создаёт компрессор для encoding. "deflate" в HTTP — это
+// формат zlib (RFC 9110, 8.4.1.2), а не голый DEFLATE
+func newEncoder(encoding string, w io.Writer) encoder {
+	if encoding == "gzip" {
+		gz := gzipPool.Get().(*gzip.Writer)
+		gz.Reset(w)
+		return &pooledEncoder{encoder: gz, pool: &gzipPool}
+	}
+	zw := zlibPool.Get().(*zlib.Writer)
+	zw.Reset(w)
+	return &pooledEncoder{encoder: zw, pool: &zlibPool}
+}
+
+// NegotiateEncoding выбирает кодировку ответа по Accept-Encoding с учётом
+// q-values. Из равных предпочитается gzip. "" — отвечать без сжатия
+func NegotiateEncoding(acceptEncoding string) string {
+	q := map[string]float64{}
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(item, ";")
+		coding = strings.ToLower(trimOWS(coding))
+		if coding == "" {
+			continue
+		}
+		weight := 1.0
+		for _, p := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(trimOWS(p), "=")
+			if !ok || !strings.EqualFold(trimOWS(name), "q") {
+				continue
+			}
+			if v, err := strconv.ParseFloat(trimOWS(value), 64); err == nil && v >= 0 && v <= 1 {
+				weight = v
+			} else {
+				weight = 0 // кривой q лучше не угадывать
+			}
+		}
+		q[coding] = weight
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range []string{"gzip", "deflate"} {
+		weight, ok := q[coding]
+		if !ok {
+			weight, ok = q["*"]
+		}
+		if ok && weight > bestQ {
+			best, bestQ = coding, weight
+		}
+	}
+	// identity;q=1 важнее сжатия с меньшим весом
+	if identity, ok := q["identity"]; ok && identity > bestQ {
+		return ""
+	}
+	return best
+}
+
+// hasToken ищет token в списке значений через запятую, без учёта регистра
+func hasToken(values []string, token string) bool {
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if strings.EqualFold(trimOWS(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"gzip, deflate, br", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"GZIP ; Q=0.8", "gzip"},
+		{"gzip;q=0", ""},
+		{"*", "gzip"},
+		{"*;q=0.5, gzip;q=0", "deflate"},
+		{"br", ""},
+		{"gzip;q=0.5, identity", ""},
+		{"gzip;q=1, identity;q=0.5", "gzip"},
+		{"gzip;q=abc, deflate;q=0.1", "deflate"},
+	}
+	for _, tt := range tests {
+		if got := NegotiateEncoding(tt.accept); got != tt.want {
+			t.Errorf("NegotiateEncoding(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+// compressHandler отдаёт size байт типа contentType, flush — потоком
+func compressHandler(contentType string, size int, flush bool) HandlerFunc {
+	return func(w *ResponseWriter, r *Request) {
+		w.EnableCompression(NegotiateEncoding(r.Headers.Get("Accept-Encoding")), 100,
+			[]string{"application/json", "text/"})
+		w.Header().Set("Content-Type", contentType)
+		chunk := strings.Repeat("a", size/2)
+		w.Write([]byte(chunk))
+		if flush {
+			w.Flush()
+		}
+		w.Write([]byte(strings.Repeat("b", size-len(chunk))))
+	}
+}
+
+func TestCompressResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		size        int
+		flush       bool
+		encoding    string // ожидаемый Content-Encoding
+		vary        bool
+	}{
+		{"gzip", "gzip", "application/json", 1000, false, "gzip", true},
+		{"deflate", "deflate", "text/plain; charset=utf-8", 1000, false, "deflate", true},
+		{"no accept-encoding", "", "application/json", 1000, false, "", true},
+		{"too small", "gzip", "application/json", 50, false, "", true},
+		{"not allowlisted", "gzip", "image/png", 1000, false, "", false},
+		{"streaming gzip", "gzip", "text/html", 100000, true, "gzip", true},
+		{"streaming small still compressed", "gzip", "text/html", 10, true, "gzip", true},
+		{"streaming identity", "", "text/html", 100000, true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := "GET / HTTP/1.1\r\nHost: x\r\nConnection: close\r\n"
+			if tt.accept != "" {
+				raw += "Accept-Encoding: " + tt.accept + "\r\n"
+			}
+			out := exchange(t, testConfig(), compressHandler(tt.contentType, tt.size, tt.flush), raw+"\r\n")
+
+			// ответ разбираем независимым парсером net/http
+			resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(out)), nil)
+			if err != nil {
+				t.Fatalf("read response: %v\n%s", err, out)
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != tt.encoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+			if got := resp.Header.Get("Vary") == "Accept-Encoding"; got != tt.vary {
+				t.Fatalf("Vary = %q, want Accept-Encoding: %v", resp.Header.Get("Vary"), tt.vary)
+			}
+			if chunked := len(resp.TransferEncoding) > 0; chunked != tt.flush {
+				t.Fatalf("chunked = %v, want %v", chunked, tt.flush)
+			}
+
+			var body io.Reader = resp.Body
+			switch tt.encoding {
+			case "gzip":
+				if body, err = gzip.NewReader(resp.Body); err != nil {
+					t.Fatal(err)
+				}
+			case "deflate":
+				if body, err = zlib.NewReader(resp.Body); err != nil {
+					t.Fatal(err)
+				}
+			}
+			data, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			want := strings.Repeat("a", tt.size/2) + strings.Repeat("b", tt.size-tt.size/2)
+			if !bytes.Equal(data, []byte(want)) {
+				t.Fatalf("body %d bytes, want %d", len(data), len(want))
+			}
+		})
+	}
+}
+
+// HEAD получает те же заголовки сжатия, что и GET, но без тела
+// и без Content-Length: сжатый размер без сжатия тела не узнать
+func TestCompressHead(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		size  int
+		flush bool
+	}{
+		{"buffered", 1000, false},
+		{"streaming", 100000, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := func(w *ResponseWriter, r *Request) {
+				w.Header().Set("ETag", `"v1"`)
+				compressHandler("application/json", tt.size, tt.flush)(w, r)
+			}
+			read := func(method string) *http.Response {
+				out := exchange(t, testConfig(), HandlerFunc(handler),
+					method+" / HTTP/1.1\r\nHost: x\r\nConnection: close\r\nAccept-Encoding: gzip\r\n\r\n")
+				resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(out)), &http.Request{Method: method})
+				if err != nil {
+					t.Fatalf("read %s response: %v\n%s", method, err, out)
+				}
+				return resp
+			}
+			get, head := read("GET"), read("HEAD")
+			for _, name := range []string{"Content-Encoding", "Content-Type", "ETag", "Vary"} {
+				if head.Header.Get(name) != get.Header.Get(name) {
+					t.Errorf("HEAD %s = %q, GET %q", name, head.Header.Get(name), get.Header.Get(name))
+				}
+			}
+			if head.Header.Get("Content-Encoding") != "gzip" || head.Header.Get("ETag") != `"v1-gzip"` {
+				t.Fatalf("HEAD headers = %v", head.Header)
+			}
+			if head.ContentLength != -1 || len(head.TransferEncoding) > 0 {
+				t.Fatalf("HEAD announces body length: %d %v", head.ContentLength, head.TransferEncoding)
+			}
+			if data, _ := io.ReadAll(head.Body); len(data) != 0 {
+				t.Fatalf("HEAD body = %q", data)
+			}
+		})
+	}
+}
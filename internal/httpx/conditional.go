@@ -0,0 +1,173 @@
+package httpx
+
+import (
+	"strings"
+	"time"
+)
+
+// TimeFormat — формат HTTP-date для Last-Modified и Date (IMF-fixdate)
+const TimeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// устаревшие форматы, которые получатель всё ещё обязан понимать (RFC 9110, 5.6.7)
+var obsoleteTimeFormats = []string{
+	"Monday, 02-Jan-06 15:04:05 GMT", // RFC 850
+	"Mon Jan _2 15:04:05 2006",       // asctime
+}
+
+// FormatTime форматирует t как HTTP-date
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+// ParseTime разбирает HTTP-date в любом из трёх допустимых форматов
+func ParseTime(s string) (time.Time, error) {
+	t, err := time.Parse(TimeFormat, s)
+	if err == nil {
+		return t, nil
+	}
+	for _, layout := range obsoleteTimeFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// CheckPreconditions проверяет условные заголовки запроса (RFC 9110, 13.2.2)
+// против текущего состояния ресурса. etag — сильный ETag в кавычках или "",
+// modified — время изменения, нулевое — неизвестно.
+// Для GET и HEAD выставляет ETag и Last-Modified. Возвращает false, если
+// ответ 304 или 412 уже записан и обработчику продолжать не нужно
+func CheckPreconditions(w *ResponseWriter, r *Request, etag string, modified time.Time) bool {
+	read := r.Method == "GET" || r.Method == "HEAD"
+	// HTTP-date точна до секунды
+	modified = modified.Truncate(time.Second)
+	if read {
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		if !modified.IsZero() {
+			w.Header().Set("Last-Modified", FormatTime(modified))
+		}
+	}
+
+	if match := r.Headers.Get("If-Match"); match != "" {
+		if _, ok := matchETag(match, etag, true); !ok {
+			w.WriteHeader(412)
+			return false
+		}
+	} else if since, err := ParseTime(r.Headers.Get("If-Unmodified-Since")); err == nil && !modified.IsZero() {
+		if modified.After(since) {
+			w.WriteHeader(412)
+			return false
+		}
+	}
+
+	if noneMatch := r.Headers.Get("If-None-Match"); noneMatch != "" {
+		matched, ok := matchETag(noneMatch, etag, false)
+		if !ok {
+			return true
+		}
+		if !read {
+			w.WriteHeader(412)
+			return false
+		}
+		if matched != "*" {
+			// клиент держит сжатое представление — подтверждаем его ETag
+			w.Header().Set("ETag", matched)
+		}
+		w.WriteHeader(304)
+		return false
+	}
+	if since, err := ParseTime(r.Headers.Get("If-Modified-Since")); err == nil && read && !modified.IsZero() {
+		if !modified.After(since) {
+			w.WriteHeader(304)
+			return false
+		}
+	}
+	return true
+}
+
+// matchETag ищет etag в списке из If-Match или If-None-Match. strong — сильное
+// сравнение (If-Match): слабые теги не совпадают ни с чем. Суффикс кодировки,
+// который добавляет сжатие ответа, не учитывается — это тот же ресурс.
+// Возвращает совпавший тег из списка
+func matchETag(list, etag string, strong bool) (string, bool) {
+	if etag == "" {
+		return "", false
+	}
+	if trimOWS(list) == "*" {
+		return "*", true
+	}
+	opaque, weak := splitETag(etag)
+	if strong && weak {
+		return "", false
+	}
+	for _, tag := range parseETags(list) {
+		tagOpaque, tagWeak := splitETag(tag)
+		if strong && tagWeak {
+			continue
+		}
+		if stripEncodingSuffix(tagOpaque) == opaque {
+			return tag, true
+		}
+	}
+	return "", false
+}
+
+// parseETags разбирает список entity-tag. Запятая допустима внутри кавычек,
+// поэтому простого Split недостаточно
+func parseETags(list string) []string {
+	var tags []string
+	for {
+		list = strings.TrimLeft(list, " \t,")
+		if list == "" {
+			return tags
+		}
+		start := 0
+		if strings.HasPrefix(list, "W/") {
+			start = 2
+		}
+		if len(list) <= start || list[start] != '"' {
+			return tags // мусор — дальше не разбираем
+		}
+		end := strings.IndexByte(list[start+1:], '"')
+		if end == -1 {
+			return tags
+		}
+		end += start + 2
+		tags = append(tags, list[:end])
+		list = list[end:]
+	}
+}
+
+// splitETag отделяет признак W/ от значения в кавычках
+func splitETag(tag string) (opaque string, weak bool) {
+	if strings.HasPrefix(tag, "W/") {
+		return tag[2:], true
+	}
+	return tag, false
+}
+
+// StrongETagMatch — сильное сравнение тега из запроса (например, If-Range)
+// с etag ресурса. Суффикс сжатия не учитывается, как и в If-Match:
+// клиент видел ETag сжатого ответа, а это тот же ресурс
+func StrongETagMatch(tag, etag string) bool {
+	_, ok := matchETag(tag, etag, true)
+	return ok && trimOWS(tag) != "*"
+}
+
+// compressedETag — ETag сжатого представления: у него другие байты,
+// поэтому и сильный валидатор свой (RFC 9110, 8.8.3)
+func compressedETag(etag, encoding string) string {
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+func stripEncodingSuffix(opaque string) string {
+	for _, encoding := range []string{"gzip", "deflate"} {
+		if base := strings.TrimSuffix(opaque, "-"+encoding+`"`); base != opaque {
+			return base + `"`
+		}
+	}
+	return opaque
+}
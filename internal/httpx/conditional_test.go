@@ -0,0 +1,103 @@
+package httpx
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	want := time.Date(1994, 11, 6, 8, 49, 37, 0, time.UTC)
+	for _, s := range []string{
+		"Sun, 06 Nov 1994 08:49:37 GMT",
+		"Sunday, 06-Nov-94 08:49:37 GMT",
+		"Sun Nov  6 08:49:37 1994",
+	} {
+		got, err := ParseTime(s)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseTime(%q) = %v, %v", s, got, err)
+		}
+	}
+	if _, err := ParseTime("yesterday"); err == nil {
+		t.Error("ParseTime accepted garbage")
+	}
+	if got := FormatTime(want.In(time.FixedZone("MSK", 3*3600))); got != "Sun, 06 Nov 1994 08:49:37 GMT" {
+		t.Errorf("FormatTime = %q", got)
+	}
+}
+
+func TestMatchETag(t *testing.T) {
+	tests := []struct {
+		list   string
+		etag   string
+		strong bool
+		want   bool
+	}{
+		{`"1-2"`, `"1-2"`, true, true},
+		{`"1-1", "1-2"`, `"1-2"`, true, true},
+		{`"a,b", "1-2"`, `"1-2"`, true, true},
+		{`"a,b"`, `"a"`, false, false},
+		{`W/"1-2"`, `"1-2"`, true, false},
+		{`W/"1-2"`, `"1-2"`, false, true},
+		{`"1-2"`, `W/"1-2"`, true, false},
+		{`"1-2-gzip"`, `"1-2"`, true, true},
+		{`"1-2-deflate"`, `"1-2"`, false, true},
+		{`"1-2-br"`, `"1-2"`, false, false},
+		{`*`, `"1-2"`, true, true},
+		{`*`, ``, true, false},
+		{`"1-3"`, `"1-2"`, false, false},
+		{`garbage`, `"1-2"`, false, false},
+	}
+	for _, tt := range tests {
+		if _, got := matchETag(tt.list, tt.etag, tt.strong); got != tt.want {
+			t.Errorf("matchETag(%q, %q, strong=%v) = %v, want %v", tt.list, tt.etag, tt.strong, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 500e6, time.UTC)
+	before := FormatTime(modified.Add(-time.Hour))
+	at := FormatTime(modified)
+
+	tests := []struct {
+		method string
+		header []string
+		status int // 0 — обработчик продолжает
+	}{
+		{"GET", nil, 0},
+		{"GET", []string{"If-None-Match", `"v1"`}, 304},
+		{"GET", []string{"If-None-Match", `W/"v1"`}, 304},
+		{"GET", []string{"If-None-Match", `"v0"`}, 0},
+		{"HEAD", []string{"If-None-Match", `*`}, 304},
+		{"GET", []string{"If-Modified-Since", at}, 304},
+		{"GET", []string{"If-Modified-Since", before}, 0},
+		// If-None-Match важнее If-Modified-Since
+		{"GET", []string{"If-None-Match", `"v0"`, "If-Modified-Since", at}, 0},
+		{"PUT", []string{"If-Match", `"v1"`}, 0},
+		{"PUT", []string{"If-Match", `"v0"`}, 412},
+		{"PUT", []string{"If-Match", `W/"v1"`}, 412},
+		{"PUT", []string{"If-Unmodified-Since", before}, 412},
+		{"PUT", []string{"If-Unmodified-Since", at}, 0},
+		// If-Match важнее If-Unmodified-Since
+		{"PUT", []string{"If-Match", `"v1"`, "If-Unmodified-Since", before}, 0},
+		{"PUT", []string{"If-None-Match", `*`}, 412},
+		{"PUT", []string{"If-Modified-Since", at}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.method, tt.header), func(t *testing.T) {
+			r := &Request{Method: tt.method, Headers: make(Header)}
+			for i := 0; i < len(tt.header); i += 2 {
+				r.Headers.Add(tt.header[i], tt.header[i+1])
+			}
+			w := newResponseWriter(nil, r, false)
+			cont := CheckPreconditions(w, r, `"v1"`, modified)
+			if cont != (tt.status == 0) || w.Status() != tt.status {
+				t.Fatalf("continue = %v, status = %d, want status %d", cont, w.Status(), tt.status)
+			}
+			if tt.method == "GET" && w.Header().Get("Last-Modified") != at {
+				t.Errorf("Last-Modified = %q", w.Header().Get("Last-Modified"))
+			}
+		})
+	}
+}
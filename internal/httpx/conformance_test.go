@@ -0,0 +1,193 @@
+package httpx
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"web-server/internal/config"
+)
+
+// testConfig — значения по умолчанию из LoadCfg с короткими таймаутами
+func testConfig() *config.Config {
+	return &config.Config{
+		KeepAliveTimeout:       100 * time.Millisecond,
+		KeepAliveMaxRequests:   100,
+		ReadHeaderTimeout:      time.Second,
+		ReadBodyTimeout:        200 * time.Millisecond,
+		WriteTimeout:           time.Second,
+		MaxRequestLineBytes:    8192,
+		MaxHeaderBytes:         65536,
+		MaxHeaderCount:         100,
+		MaxBodyBytes:           1 << 20,
+		MultipartMemoryBytes:   1 << 10,
+		MultipartMaxFileBytes:  1 << 20,
+		MultipartMaxTotalBytes: 2 << 20,
+		MultipartMaxParts:      10,
+		StrictParsing:          true,
+	}
+}
+
+// echoHandler отвечает "METHOD path len(body)" — по нему видно,
+// как сервер разрезал поток на запросы
+var echoHandler = HandlerFunc(func(w *ResponseWriter, r *Request) {
+	fmt.Fprintf(w, "%s %s %d", r.Method, r.URL.Path, len(r.Body))
+})
+
+// exchange отправляет сырые байты на ServeConn через net.Pipe
+// и возвращает всё, что сервер ответил до закрытия соединения
+func exchange(t *testing.T, cfg *config.Config, handler Handler, raw string) string {
+	t.Helper()
+	client, server := net.Pipe()
+	defer client.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ServeConn(context.Background(), server, handler, cfg)
+	}()
+	// сервер может перестать читать посреди запроса — запись не должна блокировать тест
+	go io.WriteString(client, raw)
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	out, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	<-done
+	return string(out)
+}
+
+// responses разбирает поток ответов на статусы и тела
+func responses(t *testing.T, raw string) (statuses []int, bodies []string) {
+	t.Helper()
+	br := bufio.NewReader(strings.NewReader(raw))
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return statuses, bodies
+		}
+		var minor, status int
+		if _, err := fmt.Sscanf(line, "HTTP/1.%d %d", &minor, &status); err != nil {
+			t.Fatalf("bad status line %q in %q", line, raw)
+		}
+		length := 0
+		for {
+			h, err := br.ReadString('\n')
+			if err != nil {
+				t.Fatalf("truncated headers in %q", raw)
+			}
+			if h == "\r\n" {
+				break
+			}
+			name, value, _ := strings.Cut(strings.TrimRight(h, "\r\n"), ":")
+			if strings.EqualFold(name, "Content-Length") {
+				fmt.Sscan(value, &length)
+			}
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(br, body); err != nil {
+			t.Fatalf("truncated body in %q", raw)
+		}
+		statuses = append(statuses, status)
+		bodies = append(bodies, string(body))
+	}
+}
+
+var conformanceTests = []struct {
+	name     string
+	lenient  bool
+	raw      string
+	statuses []int
+	bodies   []string // если задано — тела ответов по порядку
+}{
+	// корректные запросы
+	{name: "simple get", raw: "GET /a HTTP/1.1\r\nHost: x\r\n\r\n",
+		statuses: []int{200}, bodies: []string{"GET /a 0"}},
+	{name: "http/1.0 without host", raw: "GET /a HTTP/1.0\r\n\r\n",
+		statuses: []int{200}},
+	{name: "content-length body", raw: "POST /a HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\n\r\nhello",
+		statuses: []int{200}, bodies: []string{"POST /a 5"}},
+	{name: "chunked body", raw: "POST /a HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\n3;ext=1\r\nabc\r\n2\r\nde\r\n0\r\nX-Sum: 1\r\n\r\n",
+		statuses: []int{200}, bodies: []string{"POST /a 5"}},
+	{name: "pipelined", raw: "GET /a HTTP/1.1\r\nHost: x\r\n\r\nPOST /b HTTP/1.1\r\nHost: x\r\nContent-Length: 2\r\n\r\nhiGET /c HTTP/1.1\r\nHost: x\r\nConnection: close\r\n\r\n",
+		statuses: []int{200, 200, 200}, bodies: []string{"GET /a 0", "POST /b 2", "GET /c 0"}},
+	{name: "duplicate equal content-length", raw: "POST /a HTTP/1.1\r\nHost: x\r\nContent-Length: 2, 2\r\n\r\nhi",
+		statuses: []int{200}, bodies: []string{"POST /a 2"}},
+	{name: "absolute form", raw: "GET http://x/a%20b?q=1 HTTP/1.1\r\nHost: x\r\n\r\n",
+		statuses: []int{200}, bodies: []string{"GET /a b 0"}},
+	{name: "http/1.2 served as 1.1", raw: "GET /a HTTP/1.2\r\nHost: x\r\n\r\n",
+		statuses: []int{200}},
+	{name: "empty header value", raw: "GET /a HTTP/1.1\r\nHost: x\r\nX-Empty:\r\n\r\n",
+		statuses: []int{200}},
+	{name: "connection close stops pipeline", raw: "GET /a HTTP/1.1\r\nHost: x\r\nConnection: close\r\n\r\nGET /b HTTP/1.1\r\nHost: x\r\n\r\n",
+		statuses: []int{200}},
+
+	// некорректные запросы
+	{name: "garbage", raw: "hello\r\n\r\n", statuses: []int{400}},
+	{name: "missing host", raw: "GET /a HTTP/1.1\r\n\r\n", statuses: []int{400}},
+	{name: "two hosts", raw: "GET /a HTTP/1.1\r\nHost: x\r\nHost: y\r\n\r\n", statuses: []int{400}},
+	{name: "http/2.0", raw: "GET /a HTTP/2.0\r\nHost: x\r\n\r\n", statuses: []int{505}},
+	{name: "bad version", raw: "GET /a HTTP/1\r\nHost: x\r\n\r\n", statuses: []int{400}},
+	{name: "lowercase version", raw: "GET /a http/1.1\r\nHost: x\r\n\r\n", statuses: []int{400}},
+	{name: "bad method", raw: "G(T /a HTTP/1.1\r\nHost: x\r\n\r\n", statuses: []int{400}},
+	{name: "double space", raw: "GET  /a HTTP/1.1\r\nHost: x\r\n\r\n", statuses: []int{400}},
+	{name: "bare lf", raw: "GET /a HTTP/1.1\nHost: x\n\n", statuses: []int{400}},
+	{name: "space before colon", raw: "GET /a HTTP/1.1\r\nHost : x\r\n\r\n", statuses: []int{400}},
+	{name: "obs-fold", raw: "GET /a HTTP/1.1\r\nHost: x\r\nX-A: 1\r\n 2\r\n\r\n", statuses: []int{400}},
+	{name: "ctl in value", raw: "GET /a HTTP/1.1\r\nHost: x\r\nX-A: a\x01b\r\n\r\n", statuses: []int{400}},
+	{name: "cl and te", raw: "POST /a HTTP/1.1\r\nHost: x\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n", statuses: []int{400}},
+	{name: "conflicting content-length", raw: "POST /a HTTP/1.1\r\nHost: x\r\nContent-Length: 1\r\nContent-Length: 2\r\n\r\nab", statuses: []int{400}},
+	{name: "signed content-length", raw: "POST /a HTTP/1.1\r\nHost: x\r\nContent-Length: +2\r\n\r\nab", statuses: []int{400}},
+	{name: "te on http/1.0", raw: "POST /a HTTP/1.0\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n", statuses: []int{400}},
+	{name: "te not chunked", raw: "POST /a HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: gzip\r\n\r\n", statuses: []int{400}},
+	{name: "te gzip then chunked", raw: "POST /a HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: gzip, chunked\r\n\r\n0\r\n\r\n", statuses: []int{501}},
+	{name: "bad chunk size", raw: "POST /a HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n", statuses: []int{400}},
+	{name: "chunk without crlf", raw: "POST /a HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabcX\r\n0\r\n\r\n", statuses: []int{400}},
+	{name: "post without length", raw: "POST /a HTTP/1.1\r\nHost: x\r\n\r\n", statuses: []int{411}},
+	{name: "body too large", raw: "POST /a HTTP/1.1\r\nHost: x\r\nContent-Length: 99999999\r\n\r\n", statuses: []int{413}},
+	{name: "encoded traversal", raw: "GET /a/%2e%2e/b HTTP/1.1\r\nHost: x\r\n\r\n", statuses: []int{400}},
+	{name: "long request line", raw: "GET /" + strings.Repeat("a", 9000) + " HTTP/1.1\r\nHost: x\r\n\r\n", statuses: []int{414}},
+	{name: "huge header", raw: "GET /a HTTP/1.1\r\nHost: x\r\nX-A: " + strings.Repeat("a", 70000) + "\r\n\r\n", statuses: []int{431}},
+	{name: "unknown expect", raw: "GET /a HTTP/1.1\r\nHost: x\r\nExpect: magic\r\n\r\n", statuses: []int{417}},
+	{name: "truncated body", raw: "POST /a HTTP/1.1\r\nHost: x\r\nContent-Length: 10\r\n\r\nabc", statuses: []int{408}},
+
+	// нестрогий режим терпит то, что строгий отвергает
+	{name: "lenient bare lf", lenient: true, raw: "GET /a HTTP/1.1\nHost: x\n\n",
+		statuses: []int{200}},
+	{name: "lenient missing host", lenient: true, raw: "GET /a HTTP/1.1\r\nConnection: close\r\n\r\n",
+		statuses: []int{200}},
+	{name: "lenient space before colon", lenient: true, raw: "GET /a HTTP/1.1\r\nHost : x\r\nConnection: close\r\n\r\n",
+		statuses: []int{200}},
+	{name: "lenient obs-fold", lenient: true, raw: "GET /a HTTP/1.1\r\nX-A: 1\r\n 2\r\nConnection: close\r\n\r\n",
+		statuses: []int{200}},
+	// CL вместе с TE: тело по chunked, а соединение закрывается
+	{name: "lenient cl and te", lenient: true, raw: "POST /a HTTP/1.1\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n2\r\nab\r\n0\r\n\r\nGET /smuggled HTTP/1.1\r\n\r\n",
+		statuses: []int{200}, bodies: []string{"POST /a 2"}},
+	{name: "lenient still rejects nul", lenient: true, raw: "GET /a HTTP/1.1\r\nX-A: a\x00b\r\n\r\n",
+		statuses: []int{400}},
+}
+
+func TestConformance(t *testing.T) {
+	for _, tt := range conformanceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.StrictParsing = !tt.lenient
+
+			statuses, bodies := responses(t, exchange(t, cfg, echoHandler, tt.raw))
+			if fmt.Sprint(statuses) != fmt.Sprint(tt.statuses) {
+				t.Fatalf("statuses = %v, want %v", statuses, tt.statuses)
+			}
+			for i, want := range tt.bodies {
+				if bodies[i] != want {
+					t.Errorf("body %d = %q, want %q", i, bodies[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package httpx
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+	"web-server/internal/config"
+	"web-server/pkg/logger"
+)
+
+// Handler обрабатывает запросы соединения
+type Handler interface {
+	// Prepare вызывается после заголовков, до чтения тела. Может задать
+	// r.MaxBodyBytes или ответить сразу и вернуть false — тогда тело
+	// не читается. Соединение закрывается, только если тело у запроса было
+	Prepare(w *ResponseWriter, r *Request) bool
+	// Serve обрабатывает запрос с прочитанным телом
+	Serve(w *ResponseWriter, r *Request)
+}
+
+// HandlerFunc обрабатывает один запрос и пишет ответ в w
+type HandlerFunc func(w *ResponseWriter, r *Request)
+
+// Prepare у простой функции ничего не проверяет
+func (f HandlerFunc) Prepare(w *ResponseWriter, r *Request) bool {
+	return true
+}
+
+func (f HandlerFunc) Serve(w *ResponseWriter, r *Request) {
+	f(w, r)
+}
+
+// ServeConn обслуживает одно TCP соединение.
+// Запросы читаются в цикле, пока клиент держит keep-alive,
+// ответы на pipelined-запросы уходят в том же порядке.
+// После отмены ctx соединение дообрабатывает текущий запрос
+// с Connection: close, а ожидание нового запроса прерывается сразу
+func ServeConn(ctx context.Context, conn net.Conn, handler Handler, cfg *config.Config) {
+	defer conn.Close()
+	defer func() {
+		// паника вне обработчика (например, в парсере) закрывает только это соединение
+		if rec := recover(); rec != nil {
+			RecoverPanic(nil, nil, rec)
+		}
+	}()
+
+	logger.Log.Info("новое подключение", "address", conn.RemoteAddr())
+	if tc, ok := conn.(*tls.Conn); ok {
+		// рукопожатие укладывается в то же время, что и заголовки запроса
+		conn.SetDeadline(time.Now().Add(cfg.ReadHeaderTimeout))
+		if err := tc.HandshakeContext(ctx); err != nil {
+			logger.Log.Debug("ошибка TLS-рукопожатия", "address", conn.RemoteAddr(), "error", err)
+			return
+		}
+	}
+	reader := bufio.NewReader(conn)
+
+	// idle — соединение ждёт начала следующего запроса, его можно прервать
+	var idle atomic.Bool
+	stop := context.AfterFunc(ctx, func() {
+		if idle.Load() {
+			conn.SetReadDeadline(time.Now())
+		}
+	})
+	defer stop()
+
+	for served := 1; ; served++ {
+		// ждём следующий запрос не дольше KeepAliveTimeout
+		idle.Store(true)
+		conn.SetReadDeadline(time.Now().Add(cfg.KeepAliveTimeout))
+		if ctx.Err() != nil {
+			return
+		}
+		_, err := reader.Peek(1)
+		idle.Store(false)
+		if err != nil {
+			// клиент закрыл соединение или молчал слишком долго
+			logger.Log.Debug("соединение закрыто", "address", conn.RemoteAddr(), "served", served-1, "reason", err)
+			return
+		}
+
+		req, early, err := readRequest(conn, reader, handler, cfg)
+		if early != nil {
+			// ответ готов до чтения тела: без тела соединение можно
+			// использовать дальше, с непрочитанным телом — нет
+			if served >= cfg.KeepAliveMaxRequests || ctx.Err() != nil {
+				early.keepAlive = false
+			}
+			conn.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout))
+			if err := early.finish(); err != nil {
+				logger.Log.Error("ошибка отправки ответа", "address", conn.RemoteAddr(), "error", err)
+				return
+			}
+			if !early.KeepAlive() {
+				discardBody(conn, reader, req, cfg)
+				logger.Log.Info("обработка соединения завершена", "address", conn.RemoteAddr(), "served", served)
+				return
+			}
+			continue
+		}
+		if err != nil {
+			var netErr net.Error
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				logger.Log.Debug("клиент закрыл соединение посреди запроса", "address", conn.RemoteAddr())
+				return
+			}
+			status := 400
+			var he *httpError
+			if errors.As(err, &he) {
+				status = he.status
+			} else if errors.As(err, &netErr) && netErr.Timeout() {
+				status = 408
+			}
+			logger.Log.Error("ошибка парсинга запроса", "status", status, "error", err)
+
+			conn.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout))
+			w := newResponseWriter(conn, nil, false)
+			if he != nil {
+				for name, values := range he.header {
+					w.Header()[name] = values
+				}
+			}
+			w.WriteHeader(status)
+			w.finish()
+			return
+		}
+
+		keepAlive := req.wantsKeepAlive() && served < cfg.KeepAliveMaxRequests && ctx.Err() == nil
+		w := newResponseWriter(conn, req, keepAlive)
+		w.conn, w.reader = conn, reader
+		req.ctx = ctx
+		conn.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout))
+		serveRequest(handler.Serve, w, req)
+		req.removeUploads()
+		if w.hijacked {
+			logger.Log.Info("соединение передано обработчику и закрыто", "address", conn.RemoteAddr(), "served", served)
+			return
+		}
+		if w.aborted {
+			// без закрывающего чанка клиент увидит обрыв, а не полный ответ
+			logger.Log.Warn("ответ прерван паникой, соединение закрыто", "address", conn.RemoteAddr(), "served", served)
+			return
+		}
+		if err := w.finish(); err != nil {
+			logger.Log.Error("ошибка отправки ответа", "address", conn.RemoteAddr(), "error", err)
+			return
+		}
+
+		if !w.KeepAlive() {
+			logger.Log.Info("обработка соединения завершена", "address", conn.RemoteAddr(), "served", served)
+			return
+		}
+	}
+}
+
+// Сколько непрочитанного тела пропускается после досрочного ответа
+// перед закрытием. Закрытие с данными в приёмном буфере шлёт клиенту
+// RST, и тот может не успеть прочитать ответ — net/http делает так же
+const (
+	maxDiscardBytes = 256 << 10
+	discardTimeout  = 500 * time.Millisecond
+)
+
+// discardBody дочитывает тело отклонённого запроса, не дольше
+// discardTimeout и не больше maxDiscardBytes. Клиент, который ждёт
+// 100 Continue, тело не отправит — его не ждём
+func discardBody(conn net.Conn, reader *bufio.Reader, req *Request, cfg *config.Config) {
+	if req == nil || !req.hasBody() || req.ExpectsContinue() {
+		return
+	}
+	// ответ уже отправлен: FIN сообщает клиенту, что больше ничего не будет
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+	conn.SetReadDeadline(time.Now().Add(discardTimeout))
+	var body io.Reader = &fixedReader{r: reader, n: int64(req.contentLength)}
+	if req.transferEncoding != "" {
+		body = newChunkedReader(reader, req, maxDiscardBytes, cfg.StrictParsing)
+	}
+	io.CopyN(io.Discard, body, maxDiscardBytes)
+}
+
+// readRequest читает запрос, ограничивая по времени отдельно
+// заголовки (ReadHeaderTimeout) и тело (ReadBodyTimeout).
+// Медленный клиент получает ошибку таймаута, а не держит горутину вечно.
+// Если запрос отклонён до чтения тела (редирект, Expect, handler.Prepare),
+// возвращается ResponseWriter с готовым ответом
+func readRequest(conn net.Conn, reader *bufio.Reader, handler Handler, cfg *config.Config) (*Request, *ResponseWriter, error) {
+	// запрос начался — дедлайн от остановки сервера к нему уже не относится
+	conn.SetReadDeadline(time.Now().Add(cfg.ReadHeaderTimeout))
+	req, err := readHead(reader, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.RemoteAddr = conn.RemoteAddr().String()
+	if tc, ok := conn.(*tls.Conn); ok {
+		state := tc.ConnectionState()
+		req.TLS = &state
+	}
+
+	// тело ещё не прочитано: после отказа оно осталось бы в соединении
+	w := newResponseWriter(conn, req, req.wantsKeepAlive() && !req.hasBody())
+	if req.URL.cleaned && cfg.PathRedirectStatus != 0 {
+		// отправляем клиента на канонический путь, тело не нужно
+		w.Header().Set("Location", req.URL.String())
+		w.WriteHeader(redirectStatus(cfg.PathRedirectStatus, req.Method))
+		return req, w, nil
+	}
+	if expect := req.Headers.Get("Expect"); expect != "" && !strings.EqualFold(expect, "100-continue") {
+		w.WriteHeader(417)
+		return req, w, nil
+	}
+	if !handler.Prepare(w, req) {
+		return req, w, nil
+	}
+
+	limit := cfg.MaxBodyBytes
+	if req.MaxBodyBytes > 0 {
+		limit = req.MaxBodyBytes
+	}
+	if int64(req.contentLength) > limit {
+		return nil, nil, newHTTPError(413, "content-length %d exceeds %d bytes", req.contentLength, limit)
+	}
+	if req.ExpectsContinue() && req.hasBody() {
+		// маршрут, авторизация и размер проверены — теперь просим тело
+		conn.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout))
+		if _, err := io.WriteString(conn, "HTTP/1.1 100 Continue\r\n\r\n"); err != nil {
+			return nil, nil, err
+		}
+	}
+	conn.SetReadDeadline(time.Now().Add(cfg.ReadBodyTimeout))
+	if err := readBody(reader, req, limit, cfg); err != nil {
+		return nil, nil, err
+	}
+
+	// пока работает обработчик, из соединения никто не читает
+	conn.SetReadDeadline(time.Time{})
+	if logger.Log.Enabled(context.Background(), slog.LevelDebug) {
+		// тело не логируем: в нём бывают пароли
+		logger.Log.Debug("запрос прочитан", "method", req.Method, "path", req.URL.Path,
+			"headers", req.Headers.redacted(), "body_bytes", len(req.Body), "uploads", len(req.Uploads))
+	}
+	return req, nil, nil
+}
+
+// redirectStatus — код редиректа на канонический путь. По 301 клиенты
+// повторяют POST как GET и теряют тело, поэтому кроме GET и HEAD — всегда 308
+func redirectStatus(status int, method string) int {
+	if method != "GET" && method != "HEAD" {
+		return 308
+	}
+	return status
+}
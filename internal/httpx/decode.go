@@ -0,0 +1,141 @@
+package httpx
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+)
+
+// maxContentCodings — больше слоёв сжатия подряд никто не шлёт,
+// а каждый слой — это отдельный распаковщик в памяти
+const maxContentCodings = 2
+
+// acceptedEncodings — значение Accept-Encoding в ответе 415
+const acceptedEncodings = "gzip, deflate"
+
+// parseContentEncoding разбирает Content-Encoding запроса. Возвращает
+// кодировки в порядке применения, identity пропускается.
+// Неизвестная кодировка — 415 (RFC 9110, 15.5.16)
+func parseContentEncoding(values []string) ([]string, error) {
+	var codings []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			coding := strings.ToLower(trimOWS(item))
+			switch coding {
+			case "", "identity":
+				continue
+			case "gzip", "x-gzip":
+				coding = "gzip"
+			case "deflate":
+			default:
+				return nil, newHTTPError(415, "unsupported content-encoding: %q", coding).
+					withHeader("Accept-Encoding", acceptedEncodings)
+			}
+			codings = append(codings, coding)
+		}
+	}
+	if len(codings) > maxContentCodings {
+		return nil, newHTTPError(415, "too many content-encodings: %q", values).
+			withHeader("Accept-Encoding", acceptedEncodings)
+	}
+	return codings, nil
+}
+
+// bodyDecoder распаковывает тело по Content-Encoding. Предел limit
+// считается по распакованным байтам — так сжатая «бомба» не пройдёт
+// через лимит, рассчитанный на размер на проводе
+type bodyDecoder struct {
+	src     *sourceReader
+	codings []string
+	r       io.Reader // цепочка распаковщиков, создаётся при первом Read
+	left    int64
+	limit   int64
+}
+
+func newBodyDecoder(body io.Reader, codings []string, limit int64) *bodyDecoder {
+	return &bodyDecoder{
+		src:     &sourceReader{r: body},
+		codings: codings,
+		left:    limit,
+		limit:   limit,
+	}
+}
+
+func (d *bodyDecoder) Read(p []byte) (int, error) {
+	if d.r == nil {
+		// распаковщики читают заголовок потока сразу, поэтому создаём их
+		// лениво — и ошибки заголовка проходят через ту же обработку
+		r := io.Reader(d.src)
+		for i := len(d.codings) - 1; i >= 0; i-- {
+			next, err := newDecoder(d.codings[i], r)
+			if err != nil {
+				return 0, d.readError(err)
+			}
+			r = next
+		}
+		d.r = r
+	}
+
+	n, err := d.r.Read(p)
+	d.left -= int64(n)
+	if d.left < 0 {
+		return 0, newHTTPError(413, "decoded body exceeds %d bytes", d.limit)
+	}
+	if err != nil && err != io.EOF {
+		return n, d.readError(err)
+	}
+	return n, err
+}
+
+// decoded возвращает число уже распакованных байт
+func (d *bodyDecoder) decoded() int64 {
+	return d.limit - d.left
+}
+
+// readError отличает обрыв соединения и ошибки framing от испорченного
+// сжатого потока: первые возвращаются как есть, вторые — 400
+func (d *bodyDecoder) readError(err error) error {
+	if d.src.err != nil {
+		return d.src.err
+	}
+	if _, ok := err.(*httpError); ok {
+		return err
+	}
+	return newHTTPError(400, "invalid %s body: %v", strings.Join(d.codings, ", "), err)
+}
+
+// sourceReader запоминает ошибку исходного (ещё сжатого) тела
+type sourceReader struct {
+	r   io.Reader
+	err error
+}
+
+func (s *sourceReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if err != nil && err != io.EOF {
+		s.err = err
+	}
+	return n, err
+}
+
+// newDecoder создаёт распаковщик одной кодировки. По RFC 9110 deflate — это
+// zlib, но часть клиентов шлёт голый DEFLATE, поэтому формат определяем
+// по заголовку zlib
+func newDecoder(coding string, r io.Reader) (io.Reader, error) {
+	if coding == "gzip" {
+		return gzip.NewReader(r)
+	}
+	br := bufio.NewReader(r)
+	if head, err := br.Peek(2); err == nil && isZlibHeader(head) {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// isZlibHeader — CM=8 (deflate) и контрольная сумма CMF/FLG (RFC 1950)
+func isZlibHeader(head []byte) bool {
+	return head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0
+}
@@ -0,0 +1,118 @@
+package httpx
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		w, _ = flate.NewWriter(&buf, flate.BestCompression)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeRequestBody(t *testing.T) {
+	payload := []byte(strings.Repeat(`{"username":"u","role":"user"}`, 100))
+	gz := compress(t, "gzip", payload)
+	bomb := compress(t, "gzip", make([]byte, 2<<20))
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		chunked  bool
+		statuses []int
+		bodies   []string
+	}{
+		{name: "gzip", encoding: "gzip", body: gz,
+			statuses: []int{200, 200}, bodies: []string{fmt.Sprintf("POST /a %d", len(payload)), "GET /next 0"}},
+		{name: "x-gzip chunked", encoding: "x-gzip", body: gz, chunked: true,
+			statuses: []int{200, 200}, bodies: []string{fmt.Sprintf("POST /a %d", len(payload))}},
+		{name: "deflate as zlib", encoding: "deflate", body: compress(t, "zlib", payload),
+			statuses: []int{200, 200}, bodies: []string{fmt.Sprintf("POST /a %d", len(payload))}},
+		{name: "deflate raw", encoding: "deflate", body: compress(t, "flate", payload),
+			statuses: []int{200, 200}, bodies: []string{fmt.Sprintf("POST /a %d", len(payload))}},
+		{name: "gzip then deflate", encoding: "gzip, deflate", body: compress(t, "zlib", gz),
+			statuses: []int{200, 200}, bodies: []string{fmt.Sprintf("POST /a %d", len(payload))}},
+		{name: "identity", encoding: "identity", body: payload,
+			statuses: []int{200, 200}, bodies: []string{fmt.Sprintf("POST /a %d", len(payload))}},
+		{name: "unsupported", encoding: "br", body: payload, statuses: []int{415}},
+		{name: "too many layers", encoding: "gzip, gzip, gzip", body: gz, statuses: []int{415}},
+		{name: "corrupt gzip", encoding: "gzip", body: []byte("definitely not gzip"), statuses: []int{400}},
+		{name: "truncated gzip", encoding: "gzip", body: gz[:len(gz)/2], statuses: []int{400}},
+		{name: "zip bomb", encoding: "gzip", body: bomb, statuses: []int{413}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := "POST /a HTTP/1.1\r\nHost: x\r\nContent-Encoding: " + tt.encoding + "\r\n"
+			if tt.chunked {
+				raw += fmt.Sprintf("Transfer-Encoding: chunked\r\n\r\n%x\r\n%s\r\n0\r\n\r\n", len(tt.body), tt.body)
+			} else {
+				raw += fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(tt.body), tt.body)
+			}
+			raw += "GET /next HTTP/1.1\r\nHost: x\r\nConnection: close\r\n\r\n"
+
+			out := exchange(t, testConfig(), echoHandler, raw)
+			statuses, bodies := responses(t, out)
+			if fmt.Sprint(statuses) != fmt.Sprint(tt.statuses) {
+				t.Fatalf("statuses = %v, want %v", statuses, tt.statuses)
+			}
+			for i, want := range tt.bodies {
+				if bodies[i] != want {
+					t.Errorf("body %d = %q, want %q", i, bodies[i], want)
+				}
+			}
+			if tt.statuses[0] == 415 && !strings.Contains(out, "Accept-Encoding: gzip, deflate\r\n") {
+				t.Errorf("415 without Accept-Encoding:\n%s", out)
+			}
+		})
+	}
+}
+
+// после распаковки заголовки описывают то тело, которое видит обработчик
+func TestDecodedBodyHeaders(t *testing.T) {
+	payload := strings.Repeat(`{"username":"u","role":"user"}`, 100)
+	multipart := multipartBody(fieldPart("role", "admin"), filePart("avatar", "a.png", "png"))
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		length      int
+	}{
+		{"json", "application/json", payload, len(payload)},
+		{"multipart", "multipart/form-data; boundary=" + testBoundary, multipart, len(multipart)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gz := compress(t, "gzip", []byte(tt.body))
+			req := parseRequest(t, fmt.Sprintf("POST /a HTTP/1.1\r\nHost: x\r\nContent-Type: %s\r\n"+
+				"Content-Encoding: gzip\r\nContent-Length: %d\r\n\r\n%s", tt.contentType, len(gz), gz))
+
+			if got := req.Headers.Get("Content-Encoding"); got != "" {
+				t.Errorf("Content-Encoding = %q after decoding", got)
+			}
+			if got := req.Headers.Get("Content-Length"); got != fmt.Sprint(tt.length) {
+				t.Errorf("Content-Length = %s, want decoded %d (wire %d)", got, tt.length, len(gz))
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package httpx
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// authHandler пускает только запросы с Authorization и ограничивает
+// тело 16 байтами — как Guard и MaxBody у маршрута
+type authHandler struct {
+	prepared atomic.Bool
+}
+
+func (h *authHandler) Prepare(w *ResponseWriter, r *Request) bool {
+	h.prepared.Store(true)
+	r.MaxBodyBytes = 16
+	if !r.Headers.Has("Authorization") {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(401)
+		return false
+	}
+	return true
+}
+
+func (h *authHandler) Serve(w *ResponseWriter, r *Request) {
+	echoHandler(w, r)
+}
+
+func TestExpectContinueAfterPrepare(t *testing.T) {
+	h := &authHandler{}
+	client, server := net.Pipe()
+	defer client.Close()
+	go ServeConn(context.Background(), server, h, testConfig())
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	io.WriteString(client, "POST /a HTTP/1.1\r\nHost: x\r\nAuthorization: Bearer t\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n")
+	br := bufio.NewReader(client)
+	line, err := br.ReadString('\n')
+	if err != nil || line != "HTTP/1.1 100 Continue\r\n" {
+		t.Fatalf("interim response = %q, %v", line, err)
+	}
+	if !h.prepared.Load() {
+		t.Fatal("100 Continue sent before Prepare")
+	}
+	if blank, _ := br.ReadString('\n'); blank != "\r\n" {
+		t.Fatalf("100 Continue not terminated: %q", blank)
+	}
+
+	// тело отправляем только теперь, как настоящий клиент
+	io.WriteString(client, "hello")
+	line, _ = br.ReadString('\n')
+	if !strings.HasPrefix(line, "HTTP/1.1 200 ") {
+		t.Fatalf("final response = %q", line)
+	}
+}
+
+func TestRejectBeforeBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		statuses []int
+	}{
+		// тело не отправлено и не прочитано — соединение закрывается
+		{name: "unauthorized with expect",
+			raw:      "POST /a HTTP/1.1\r\nHost: x\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n",
+			statuses: []int{401}},
+		{name: "too large with expect",
+			raw:      "POST /a HTTP/1.1\r\nHost: x\r\nAuthorization: Bearer t\r\nExpect: 100-continue\r\nContent-Length: 17\r\n\r\n",
+			statuses: []int{413}},
+		// без Expect тело уже в пути, но читать его незачем
+		{name: "unauthorized with body",
+			raw:      "POST /a HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\n\r\nhelloGET /b HTTP/1.1\r\nHost: x\r\n\r\n",
+			statuses: []int{401}},
+		// отказ без тела не мешает следующему запросу
+		{name: "unauthorized keeps connection",
+			raw:      "GET /a HTTP/1.1\r\nHost: x\r\n\r\nGET /b HTTP/1.1\r\nHost: x\r\nAuthorization: Bearer t\r\nConnection: close\r\n\r\n",
+			statuses: []int{401, 200}},
+		{name: "unknown expect keeps connection",
+			raw:      "GET /a HTTP/1.1\r\nHost: x\r\nExpect: magic\r\n\r\nGET /b HTTP/1.1\r\nHost: x\r\nAuthorization: Bearer t\r\nConnection: close\r\n\r\n",
+			statuses: []int{417, 200}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := exchange(t, testConfig(), &authHandler{}, tt.raw)
+			if strings.Contains(out, "100 Continue") {
+				t.Fatalf("100 Continue sent for rejected request: %q", out)
+			}
+			statuses, _ := responses(t, out)
+			if fmt.Sprint(statuses) != fmt.Sprint(tt.statuses) {
+				t.Fatalf("statuses = %v, want %v", statuses, tt.statuses)
+			}
+			if keepAlive := strings.Count(out, "Connection: keep-alive"); keepAlive != len(statuses)-1 {
+				t.Fatalf("%d keep-alive responses, want %d: %q", keepAlive, len(statuses)-1, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// FuzzReadRequest: парсер не паникует, не зависает и не выходит за лимиты
+// ни в строгом, ни в нестрогом режиме.
+// Запуск: go test ./internal/httpx -run='^$' -fuzz=FuzzReadRequest
+func FuzzReadRequest(f *testing.F) {
+	for _, tt := range conformanceTests {
+		f.Add([]byte(tt.raw), tt.lenient)
+	}
+	f.Add([]byte("POST /a HTTP/1.1\r\nHost: x\r\nContent-Type: multipart/form-data; boundary=b\r\nContent-Length: 44\r\n\r\n--b\r\nContent-Disposition: form-data; name=a\r\n\r\n1\r\n--b--"), false)
+	f.Add([]byte("POST /a HTTP/1.1\r\nHost: x\r\nContent-Encoding: gzip\r\nContent-Length: 23\r\n\r\n"+
+		"\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x02\x04\x00\x00\xff\xff\x03\x00\x00\x00\x00\x00"), false)
+	f.Add([]byte("POST /a HTTP/1.1\r\nHost: x\r\nContent-Type: application/x-www-form-urlencoded\r\nContent-Length: 7\r\n\r\na=1&b=%"), false)
+
+	f.Fuzz(func(t *testing.T, data []byte, lenient bool) {
+		cfg := testConfig()
+		cfg.StrictParsing = !lenient
+		cfg.UploadTempDir = t.TempDir()
+
+		req, err := ReadRequest(bufio.NewReader(bytes.NewReader(data)), cfg)
+		if err != nil {
+			var he *httpError
+			if errors.As(err, &he) && (he.status < 400 || he.status > 599) {
+				t.Fatalf("error status %d: %v", he.status, err)
+			}
+			assertNoUploads(t, cfg.UploadTempDir)
+			return
+		}
+		defer req.removeUploads()
+
+		if req.Method == "" || req.URL == nil || !strings.HasPrefix(req.URL.Path, "/") && req.URL.Path != "*" {
+			t.Fatalf("incomplete request: %+v", req)
+		}
+		if req.Version != "HTTP/1.0" && req.Version != "HTTP/1.1" {
+			t.Fatalf("version %q", req.Version)
+		}
+		if int64(len(req.Body)) > cfg.MaxBodyBytes {
+			t.Fatalf("body %d bytes exceeds limit %d", len(req.Body), cfg.MaxBodyBytes)
+		}
+		if req.transferEncoding != "" && req.Headers.Has("Content-Length") {
+			t.Fatalf("both framings survived parsing")
+		}
+	})
+}
+
+// FuzzParseMultipart: части не превышают лимитов, а временные файлы
+// удаляются и при ошибке разбора.
+// Запуск: go test ./internal/httpx -run='^$' -fuzz=FuzzParseMultipart
+func FuzzParseMultipart(f *testing.F) {
+	f.Add("b", []byte("--b\r\nContent-Disposition: form-data; name=\"a\"\r\n\r\n1\r\n--b--\r\n"))
+	f.Add("b", []byte("preamble\r\n--b\r\nContent-Disposition: form-data; name=\"f\"; filename=\"C:\\\\x\\\\a.txt\"\r\nContent-Type: text/plain\r\n\r\n"+
+		strings.Repeat("x", 3000)+"\r\n--b\r\nContent-Disposition: form-data; name=\"g\"\r\n\r\n\r\n--b--epilogue"))
+	f.Add("xyz", []byte("--xyz  \r\nContent-Disposition: form-data; name*=UTF-8''%D0%B0\r\n\r\n\r\n--x\r\n--xyz--"))
+	f.Add("b", []byte("--b\r\nContent-Disposition: form-data\r\n\r\n--b--"))
+	f.Add("b", []byte("--b\r\n\r\n"))
+
+	f.Fuzz(func(t *testing.T, boundary string, body []byte) {
+		if boundary == "" || len(boundary) > maxBoundaryLen {
+			return
+		}
+		cfg := testConfig()
+		cfg.UploadTempDir = t.TempDir()
+		limits := multipartLimitsFromConfig(cfg)
+
+		req := &Request{}
+		err := parseMultipart(req, bytes.NewReader(body), boundary, limits)
+		if err != nil {
+			assertNoUploads(t, cfg.UploadTempDir)
+			return
+		}
+
+		var total int64
+		for _, u := range req.Uploads {
+			if u.Size > limits.maxFile {
+				t.Fatalf("upload %q is %d bytes, limit %d", u.Filename, u.Size, limits.maxFile)
+			}
+			if strings.ContainsAny(u.Filename, `/\`) {
+				t.Fatalf("filename %q not sanitized", u.Filename)
+			}
+			rc, err := u.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := io.Copy(io.Discard, rc)
+			rc.Close()
+			if err != nil || n != u.Size {
+				t.Fatalf("upload content %d bytes, Size %d, err %v", n, u.Size, err)
+			}
+			total += u.Size
+		}
+		for _, values := range req.FormData {
+			for _, v := range values {
+				total += int64(len(v))
+			}
+		}
+		if total > limits.maxTotal {
+			t.Fatalf("form is %d bytes, limit %d", total, limits.maxTotal)
+		}
+		if len(req.Uploads)+len(req.FormData) > limits.maxParts {
+			t.Fatalf("%d parts, limit %d", len(req.Uploads)+len(req.FormData), limits.maxParts)
+		}
+
+		req.removeUploads()
+		assertNoUploads(t, cfg.UploadTempDir)
+	})
+}
+
+// assertNoUploads проверяет, что во временном каталоге не осталось файлов
+func assertNoUploads(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("%d temp files left in %s", len(entries), dir)
+	}
+}
@@ -0,0 +1,115 @@
+package httpx
+
+import (
+	"sort"
+	"strings"
+)
+
+// Header — заголовки запроса или ответа. Имена хранятся в канонической
+// форме (Content-Type), поэтому поиск не зависит от регистра,
+// а повторяющиеся заголовки не затирают друг друга
+type Header map[string][]string
+
+// Get возвращает первое значение заголовка или ""
+func (h Header) Get(name string) string {
+	if v := h[CanonicalHeaderKey(name)]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Values возвращает все значения заголовка
+func (h Header) Values(name string) []string {
+	return h[CanonicalHeaderKey(name)]
+}
+
+// Has сообщает, есть ли заголовок
+func (h Header) Has(name string) bool {
+	_, ok := h[CanonicalHeaderKey(name)]
+	return ok
+}
+
+// Add добавляет значение к заголовку
+func (h Header) Add(name, value string) {
+	key := CanonicalHeaderKey(name)
+	h[key] = append(h[key], value)
+}
+
+// Set заменяет все значения заголовка одним
+func (h Header) Set(name, value string) {
+	h[CanonicalHeaderKey(name)] = []string{value}
+}
+
+// Del удаляет заголовок
+func (h Header) Del(name string) {
+	delete(h, CanonicalHeaderKey(name))
+}
+
+// HasToken сообщает, есть ли token в списке через запятую, например
+// "upgrade" в Connection. Регистр не учитывается
+func (h Header) HasToken(name, token string) bool {
+	return hasToken(h.Values(name), token)
+}
+
+// credentialHeaders — заголовки с токенами и сессиями, в лог не попадают
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// redacted возвращает копию для лога со скрытыми учётными данными
+func (h Header) redacted() Header {
+	c := h.Clone()
+	for _, name := range credentialHeaders {
+		if c.Has(name) {
+			c.Set(name, "[скрыто]")
+		}
+	}
+	return c
+}
+
+// Clone возвращает независимую копию
+func (h Header) Clone() Header {
+	c := make(Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+// sortedKeys возвращает имена заголовков по алфавиту
+func (h Header) sortedKeys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CanonicalHeaderKey приводит имя заголовка к виду "Content-Type".
+// Имена с недопустимыми символами возвращаются без изменений
+func CanonicalHeaderKey(name string) string {
+	for i := 0; i < len(name); i++ {
+		if !isTokenChar(name[i]) {
+			return name
+		}
+	}
+
+	b := []byte(name)
+	upper := true
+	for i, c := range b {
+		if upper && 'a' <= c && c <= 'z' {
+			b[i] = c - ('a' - 'A')
+		} else if !upper && 'A' <= c && c <= 'Z' {
+			b[i] = c + ('a' - 'A')
+		}
+		upper = c == '-'
+	}
+	return string(b)
+}
+
+// isTokenChar — символ, допустимый в token по RFC 9110, 5.6.2
+func isTokenChar(c byte) bool {
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) != -1
+}
@@ -0,0 +1,359 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"strings"
+	"web-server/internal/config"
+)
+
+const (
+	maxBoundaryLen       = 70 // RFC 2046, 5.1.1
+	maxPartHeaderBytes   = 16 * 1024
+	multipartReaderBytes = 64 * 1024
+)
+
+// UploadReq — файл из multipart/form-data. Маленькие файлы держатся
+// в памяти, большие сбрасываются во временный файл, который удаляется
+// после обработки запроса
+type UploadReq struct {
+	FieldName   string // имя поля формы
+	Filename    string // имя файла без пути
+	Size        int64
+	ContentType string
+	Header      Header // все заголовки части
+
+	content []byte
+	tmpPath string
+}
+
+// Open открывает содержимое файла для чтения
+func (u *UploadReq) Open() (io.ReadCloser, error) {
+	if u.tmpPath != "" {
+		return os.Open(u.tmpPath)
+	}
+	return io.NopCloser(bytes.NewReader(u.content)), nil
+}
+
+// InMemory сообщает, лежит ли файл в памяти, а не на диске
+func (u *UploadReq) InMemory() bool {
+	return u.tmpPath == ""
+}
+
+func (u *UploadReq) remove() {
+	if u.tmpPath != "" {
+		os.Remove(u.tmpPath)
+		u.tmpPath = ""
+	}
+}
+
+// multipartLimits — ограничения на разбор одной формы
+type multipartLimits struct {
+	memory   int64 // больше — файл уходит на диск, поле — 413
+	maxFile  int64
+	maxTotal int64
+	maxParts int
+	tempDir  string
+}
+
+func multipartLimitsFromConfig(cfg *config.Config) multipartLimits {
+	return multipartLimits{
+		memory:   cfg.MultipartMemoryBytes,
+		maxFile:  cfg.MultipartMaxFileBytes,
+		maxTotal: cfg.MultipartMaxTotalBytes,
+		maxParts: cfg.MultipartMaxParts,
+		tempDir:  cfg.UploadTempDir,
+	}
+}
+
+// multipartBoundary достаёт boundary из Content-Type multipart/form-data.
+// ok == false, если тело не multipart/form-data
+func multipartBoundary(contentType string) (string, bool, error) {
+	if contentType == "" {
+		return "", false, nil
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" {
+		return "", false, nil
+	}
+	boundary := params["boundary"]
+	if boundary == "" || len(boundary) > maxBoundaryLen || strings.HasSuffix(boundary, " ") {
+		return "", true, newHTTPError(400, "invalid multipart boundary: %q", boundary)
+	}
+	return boundary, true, nil
+}
+
+// parseMultipart потоково разбирает тело multipart/form-data из body.
+// Текстовые поля попадают в req.FormData, файлы — в req.Uploads.
+// При ошибке уже созданные временные файлы удаляются
+func parseMultipart(req *Request, body io.Reader, boundary string, limits multipartLimits) (err error) {
+	defer func() {
+		if err != nil {
+			req.removeUploads()
+		}
+	}()
+
+	mr := &multipartReader{
+		r:         bufio.NewReaderSize(body, multipartReaderBytes),
+		dashBound: []byte("--" + boundary),
+		delim:     []byte("\r\n--" + boundary),
+		limits:    limits,
+	}
+	if req.FormData == nil {
+		req.FormData = Values{}
+	}
+
+	if err := mr.skipPreamble(); err != nil {
+		return err
+	}
+	for parts := 0; ; parts++ {
+		last, err := mr.afterBoundary()
+		if err != nil {
+			return err
+		}
+		if last {
+			// эпилог игнорируем
+			_, err := io.Copy(io.Discard, mr.r)
+			return err
+		}
+		if parts >= limits.maxParts {
+			return newHTTPError(413, "more than %d multipart parts", limits.maxParts)
+		}
+		if err := mr.readPart(req); err != nil {
+			return err
+		}
+	}
+}
+
+type multipartReader struct {
+	r         *bufio.Reader
+	dashBound []byte // "--boundary"
+	delim     []byte // "\r\n--boundary" — разделитель после тела части
+	limits    multipartLimits
+	total     int64
+}
+
+// skipPreamble пропускает всё до первого "--boundary"
+func (mr *multipartReader) skipPreamble() error {
+	if start, err := mr.r.Peek(len(mr.dashBound)); err == nil && bytes.Equal(start, mr.dashBound) {
+		_, err := mr.r.Discard(len(mr.dashBound))
+		return err
+	}
+	_, err := mr.copyUntilDelim(io.Discard, -1)
+	return err
+}
+
+// afterBoundary разбирает хвост строки разделителя: "--" у последнего,
+// иначе необязательные пробелы и CRLF
+func (mr *multipartReader) afterBoundary() (last bool, err error) {
+	line, err := readLine(mr.r, maxPartHeaderBytes, false)
+	if err != nil {
+		return false, multipartReadError(err)
+	}
+	if strings.HasPrefix(line, "--") {
+		return true, nil
+	}
+	if strings.TrimRight(line, " \t") != "" {
+		return false, newHTTPError(400, "invalid multipart boundary line")
+	}
+	return false, nil
+}
+
+// readPart читает заголовки и тело одной части
+func (mr *multipartReader) readPart(req *Request) error {
+	header, err := mr.readPartHeader()
+	if err != nil {
+		return err
+	}
+
+	disposition, params, err := mime.ParseMediaType(header.Get("Content-Disposition"))
+	if err != nil || disposition != "form-data" || params["name"] == "" {
+		return newHTTPError(400, "invalid content-disposition in multipart part")
+	}
+	name := params["name"]
+	// mime.ParseMediaType уже декодировал filename*=UTF-8''... в filename
+	filename, isFile := params["filename"]
+
+	if !isFile {
+		var buf bytes.Buffer
+		n, err := mr.copyUntilDelim(&buf, mr.limits.memory)
+		if err != nil {
+			return err
+		}
+		if err := mr.count(n); err != nil {
+			return err
+		}
+		req.FormData.Add(name, buf.String())
+		return nil
+	}
+
+	upload := &UploadReq{
+		FieldName:   name,
+		Filename:    sanitizeFilename(filename),
+		ContentType: header.Get("Content-Type"),
+		Header:      header,
+	}
+	if upload.ContentType == "" {
+		upload.ContentType = "application/octet-stream"
+	}
+	// добавляем сразу, чтобы временный файл удалился и при ошибке
+	req.Uploads = append(req.Uploads, upload)
+
+	sw := &spillWriter{memory: mr.limits.memory, tempDir: mr.limits.tempDir}
+	n, err := mr.copyUntilDelim(sw, mr.limits.maxFile)
+	upload.content, upload.tmpPath = sw.buf.Bytes(), sw.path()
+	if closeErr := sw.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	upload.Size = n
+	return mr.count(n)
+}
+
+func (mr *multipartReader) readPartHeader() (Header, error) {
+	header := make(Header)
+	total := 0
+	for {
+		line, err := readLine(mr.r, maxPartHeaderBytes-total, false)
+		if err == errLineTooLong {
+			return nil, newHTTPError(431, "multipart part headers exceed %d bytes", maxPartHeaderBytes)
+		}
+		if err != nil {
+			return nil, multipartReadError(err)
+		}
+		if line == "" {
+			return header, nil
+		}
+		total += len(line) + 2
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || name == "" || strings.TrimSpace(name) != name {
+			return nil, newHTTPError(400, "invalid multipart header line: %q", line)
+		}
+		header.Add(name, strings.TrimSpace(value))
+	}
+}
+
+// count учитывает размер части в общем лимите формы
+func (mr *multipartReader) count(n int64) error {
+	mr.total += n
+	if mr.total > mr.limits.maxTotal {
+		return newHTTPError(413, "multipart body exceeds %d bytes", mr.limits.maxTotal)
+	}
+	return nil
+}
+
+// copyUntilDelim копирует данные в dst до разделителя "\r\n--boundary"
+// и пропускает сам разделитель. Больше max байт — 413 (max < 0 — без лимита).
+// В памяти держится только буфер reader'а, поэтому часть любого размера
+// не загружается целиком
+func (mr *multipartReader) copyUntilDelim(dst io.Writer, max int64) (int64, error) {
+	var n int64
+	for {
+		buf, err := mr.r.Peek(multipartReaderBytes)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return n, multipartReadError(err)
+		}
+
+		if i := bytes.Index(buf, mr.delim); i != -1 {
+			if err := mr.write(dst, buf[:i], &n, max); err != nil {
+				return n, err
+			}
+			_, err := mr.r.Discard(i + len(mr.delim))
+			return n, err
+		}
+		if err == io.EOF {
+			return n, multipartReadError(err)
+		}
+
+		// хвост буфера может оказаться началом разделителя — его оставляем
+		safe := len(buf) - len(mr.delim) + 1
+		if err := mr.write(dst, buf[:safe], &n, max); err != nil {
+			return n, err
+		}
+		if _, err := mr.r.Discard(safe); err != nil {
+			return n, err
+		}
+	}
+}
+
+func (mr *multipartReader) write(dst io.Writer, p []byte, n *int64, max int64) error {
+	if max >= 0 && *n+int64(len(p)) > max {
+		return newHTTPError(413, "multipart part exceeds %d bytes", max)
+	}
+	if _, err := dst.Write(p); err != nil {
+		return err
+	}
+	*n += int64(len(p))
+	return nil
+}
+
+// spillWriter пишет в память, а после memory байт переносит всё
+// во временный файл
+type spillWriter struct {
+	memory  int64
+	tempDir string
+	buf     bytes.Buffer
+	file    *os.File
+}
+
+func (sw *spillWriter) Write(p []byte) (int, error) {
+	if sw.file == nil && int64(sw.buf.Len()+len(p)) <= sw.memory {
+		return sw.buf.Write(p)
+	}
+	if sw.file == nil {
+		f, err := os.CreateTemp(sw.tempDir, "upload-*")
+		if err != nil {
+			return 0, fmt.Errorf("failed to create temp file: %w", err)
+		}
+		sw.file = f
+		if _, err := f.Write(sw.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		sw.buf.Reset()
+	}
+	return sw.file.Write(p)
+}
+
+func (sw *spillWriter) path() string {
+	if sw.file == nil {
+		return ""
+	}
+	return sw.file.Name()
+}
+
+func (sw *spillWriter) close() error {
+	if sw.file == nil {
+		return nil
+	}
+	return sw.file.Close()
+}
+
+// sanitizeFilename оставляет только имя файла: некоторые браузеры
+// присылают полный путь вида C:\Users\...\photo.png
+func sanitizeFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// multipartReadError: io.EOF значит, что тело закончилось раньше
+// закрывающего разделителя — это ошибка клиента, а не обрыв соединения
+func multipartReadError(err error) error {
+	if err == errLineTooLong {
+		return newHTTPError(400, "multipart line too long")
+	}
+	if err == io.EOF {
+		return newHTTPError(400, "unexpected end of multipart body")
+	}
+	return fmt.Errorf("failed to read multipart body: %w", err)
+}
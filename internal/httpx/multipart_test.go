@@ -0,0 +1,232 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testBoundary = "xB0und"
+
+// multipartBody собирает multipart/form-data с boundary testBoundary
+func multipartBody(parts ...string) string {
+	var sb strings.Builder
+	for _, p := range parts {
+		sb.WriteString("--" + testBoundary + "\r\n" + p + "\r\n")
+	}
+	sb.WriteString("--" + testBoundary + "--\r\n")
+	return sb.String()
+}
+
+func filePart(name, filename, content string) string {
+	return fmt.Sprintf("Content-Disposition: form-data; name=%q; filename=%q\r\nContent-Type: application/octet-stream\r\n\r\n%s",
+		name, filename, content)
+}
+
+func fieldPart(name, value string) string {
+	return fmt.Sprintf("Content-Disposition: form-data; name=%q\r\n\r\n%s", name, value)
+}
+
+// binaryContent — все значения байт, почти-разделители и CRLF подряд,
+// повторённые до size байт
+func binaryContent(size int) string {
+	var sb strings.Builder
+	for sb.Len() < size {
+		for b := range 256 {
+			sb.WriteByte(byte(b))
+		}
+		sb.WriteString("\r\n--xB0un\r\n-\r\n--\r\r\n\x00")
+	}
+	return sb.String()[:size]
+}
+
+func testLimits(t *testing.T) multipartLimits {
+	return multipartLimits{memory: 1 << 10, maxFile: 1 << 20, maxTotal: 2 << 20, maxParts: 10, tempDir: t.TempDir()}
+}
+
+func TestParseMultipart(t *testing.T) {
+	binary := binaryContent(3000)
+	// больше буфера чтения — разделитель ищется на стыке буферов
+	large := binaryContent(multipartReaderBytes + 100)
+
+	type upload struct {
+		field, filename, content string
+		inMemory                 bool
+	}
+	tests := []struct {
+		name    string
+		body    string
+		limits  func(*multipartLimits)
+		fields  Values
+		uploads []upload
+		status  int // ожидаемая ошибка, 0 — форма разбирается
+	}{
+		{name: "fields and file",
+			body:    multipartBody(fieldPart("role", "admin"), fieldPart("role", "user"), filePart("f", "a.txt", "hello")),
+			fields:  Values{"role": {"admin", "user"}},
+			uploads: []upload{{"f", "a.txt", "hello", true}}},
+		{name: "empty file",
+			body:    multipartBody(filePart("f", "empty", "")),
+			fields:  Values{},
+			uploads: []upload{{"f", "empty", "", true}}},
+		{name: "binary in memory",
+			body:    multipartBody(filePart("f", "b.bin", binary[:1<<10])),
+			fields:  Values{},
+			uploads: []upload{{"f", "b.bin", binary[:1<<10], true}}},
+		{name: "binary spills to disk",
+			body:    multipartBody(filePart("f", "b.bin", binary[:1<<10+1])),
+			fields:  Values{},
+			uploads: []upload{{"f", "b.bin", binary[:1<<10+1], false}}},
+		{name: "larger than read buffer",
+			body:    multipartBody(filePart("f", "l.bin", large), fieldPart("after", "ok")),
+			fields:  Values{"after": {"ok"}},
+			uploads: []upload{{"f", "l.bin", large, false}}},
+		{name: "preamble and epilogue",
+			body:   "preamble\r\n" + multipartBody(fieldPart("a", "1")) + "epilogue",
+			fields: Values{"a": {"1"}}},
+		{name: "rfc 5987 filename",
+			body:    multipartBody("Content-Disposition: form-data; name=\"f\"; filename*=UTF-8''%D0%BE%D1%82%D1%87%D1%91%D1%82.pdf\r\n\r\npdf"),
+			fields:  Values{},
+			uploads: []upload{{"f", "отчёт.pdf", "pdf", true}}},
+		{name: "windows path stripped",
+			body:    multipartBody(filePart("f", `C:\Users\me\photo.png`, "png")),
+			fields:  Values{},
+			uploads: []upload{{"f", "photo.png", "png", true}}},
+
+		// ограничения
+		{name: "file too large",
+			body:   multipartBody(filePart("f", "b.bin", binary)),
+			limits: func(l *multipartLimits) { l.maxFile = 2000 },
+			status: 413},
+		{name: "file at limit",
+			body:    multipartBody(filePart("f", "b.bin", binary[:2000])),
+			limits:  func(l *multipartLimits) { l.maxFile = 2000 },
+			fields:  Values{},
+			uploads: []upload{{"f", "b.bin", binary[:2000], false}}},
+		{name: "total too large",
+			body:   multipartBody(filePart("f", "1", binary[:1500]), filePart("g", "2", binary[:1500])),
+			limits: func(l *multipartLimits) { l.maxTotal = 2500 },
+			status: 413},
+		{name: "field too large",
+			body:   multipartBody(fieldPart("a", strings.Repeat("x", 1<<10+1))),
+			status: 413},
+		{name: "too many parts",
+			body:   multipartBody(fieldPart("a", "1"), fieldPart("b", "2"), fieldPart("c", "3")),
+			limits: func(l *multipartLimits) { l.maxParts = 2 },
+			status: 413},
+
+		// испорченные формы
+		{name: "missing close delimiter", body: "--" + testBoundary + "\r\n" + fieldPart("a", "1"), status: 400},
+		{name: "part without name", body: multipartBody("Content-Disposition: form-data\r\n\r\nx"), status: 400},
+		{name: "not form-data", body: multipartBody("Content-Disposition: attachment; name=\"a\"\r\n\r\nx"), status: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := testLimits(t)
+			if tt.limits != nil {
+				tt.limits(&limits)
+			}
+			req := &Request{}
+			err := parseMultipart(req, strings.NewReader(tt.body), testBoundary, limits)
+			defer req.removeUploads()
+
+			if tt.status != 0 {
+				var he *httpError
+				if !errors.As(err, &he) || he.status != tt.status {
+					t.Fatalf("error = %v, want status %d", err, tt.status)
+				}
+				// временные файлы неудачной формы удалены сразу
+				if entries, _ := os.ReadDir(limits.tempDir); len(entries) != 0 {
+					t.Fatalf("temp files left: %v", entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(req.FormData) != fmt.Sprint(tt.fields) {
+				t.Errorf("fields = %v, want %v", req.FormData, tt.fields)
+			}
+			if len(req.Uploads) != len(tt.uploads) {
+				t.Fatalf("%d uploads, want %d", len(req.Uploads), len(tt.uploads))
+			}
+			for i, want := range tt.uploads {
+				u := req.Uploads[i]
+				if u.FieldName != want.field || u.Filename != want.filename || u.InMemory() != want.inMemory {
+					t.Errorf("upload %d = %q %q in memory %v, want %+v", i, u.FieldName, u.Filename, u.InMemory(), want)
+				}
+				f, err := u.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, _ := io.ReadAll(f)
+				f.Close()
+				if string(data) != want.content || u.Size != int64(len(want.content)) {
+					t.Errorf("upload %d: %d bytes (Size %d), want %d, equal = %v",
+						i, len(data), u.Size, len(want.content), string(data) == want.content)
+				}
+			}
+		})
+	}
+}
+
+// failingReader отдаёт данные, а затем ошибку вместо EOF — обрыв соединения
+type failingReader struct{ r io.Reader }
+
+func (f failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func TestReadBodyRemovesUploadsOnDrainError(t *testing.T) {
+	cfg := testConfig()
+	cfg.UploadTempDir = t.TempDir()
+
+	// форма разобрана целиком, а хвост сжатого тела оборвался
+	form := multipartBody(filePart("f", "big.bin", strings.Repeat("x", 4<<10)))
+	body := compress(t, "flate", []byte(form))
+	head := fmt.Sprintf("POST /a HTTP/1.1\r\nHost: x\r\nContent-Type: multipart/form-data; boundary="+testBoundary+"\r\n"+
+		"Content-Encoding: deflate\r\nContent-Length: %d\r\n\r\n", len(body)+10)
+	reader := bufio.NewReader(failingReader{io.MultiReader(strings.NewReader(head), bytes.NewReader(body))})
+
+	req, err := readHead(reader, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := readBody(reader, req, cfg.MaxBodyBytes, cfg); err == nil {
+		t.Fatal("drain error lost")
+	}
+	if len(req.Uploads) == 0 {
+		t.Fatal("upload was not parsed before the drain")
+	}
+	entries, _ := os.ReadDir(cfg.UploadTempDir)
+	if len(entries) != 0 {
+		t.Fatalf("temp files left: %v", entries)
+	}
+}
+
+// ошибка разбора формы доходит до клиента статусом, обработчик не вызывается
+func TestMultipartLimitStatus(t *testing.T) {
+	cfg := testConfig()
+	cfg.UploadTempDir = t.TempDir()
+	cfg.MultipartMaxFileBytes = 2000
+
+	form := multipartBody(filePart("f", "b.bin", binaryContent(3000)))
+	raw := fmt.Sprintf("POST /a HTTP/1.1\r\nHost: x\r\nContent-Type: multipart/form-data; boundary=%s\r\nContent-Length: %d\r\n\r\n%s",
+		testBoundary, len(form), form)
+	statuses, _ := responses(t, exchange(t, cfg, echoHandler, raw))
+	if fmt.Sprint(statuses) != "[413]" {
+		t.Fatalf("statuses = %v, want [413]", statuses)
+	}
+	if entries, _ := os.ReadDir(cfg.UploadTempDir); len(entries) != 0 {
+		t.Fatalf("temp files left: %v", entries)
+	}
+}
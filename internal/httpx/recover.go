@@ -0,0 +1,54 @@
+package httpx
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"web-server/pkg/logger"
+)
+
+// panics — число перехваченных паник за время работы сервера
+var panics atomic.Int64
+
+// Panics возвращает число паник, перехваченных при обработке запросов
+func Panics() int64 {
+	return panics.Load()
+}
+
+// RecoverPanic логирует панику со стеком, увеличивает счётчик ошибок
+// и, если ответ ещё не начал отправляться, отвечает 500 с JSON-телом.
+// Если заголовки уже ушли, ответ не завершается: ServeConn обрывает
+// соединение, чтобы клиент не принял обрезанное тело за целое.
+// Соединение после паники закрывается: его состояние неизвестно.
+// Вызывается из defer после recover()
+func RecoverPanic(w *ResponseWriter, r *Request, rec any) {
+	total := panics.Add(1)
+
+	attrs := []any{"panic", fmt.Sprint(rec), "total", total, "stack", string(debug.Stack())}
+	if r != nil {
+		attrs = append(attrs, "method", r.Method, "path", r.URL.Path, "address", r.RemoteAddr)
+	}
+	logger.Log.Error("паника при обработке запроса", attrs...)
+
+	if w == nil {
+		return
+	}
+	w.keepAlive = false
+	if !w.Reset() {
+		w.aborted = true
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(500)
+	w.Write([]byte(`{"error":"internal server error"}`))
+}
+
+// serveRequest вызывает обработчик, не давая панике выйти за пределы запроса
+func serveRequest(handler HandlerFunc, w *ResponseWriter, r *Request) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			RecoverPanic(w, r, rec)
+		}
+	}()
+	handler(w, r)
+}
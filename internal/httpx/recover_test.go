@@ -0,0 +1,52 @@
+package httpx
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPanicBeforeHeaders(t *testing.T) {
+	before := Panics()
+	handler := HandlerFunc(func(w *ResponseWriter, r *Request) {
+		w.Header().Set("X-Partial", "1")
+		w.Write([]byte("partial"))
+		panic("boom")
+	})
+
+	// следующий запрос в том же соединении не обслуживается
+	out := exchange(t, testConfig(), handler, "GET /a HTTP/1.1\r\nHost: x\r\n\r\nGET /b HTTP/1.1\r\nHost: x\r\n\r\n")
+	statuses, bodies := responses(t, out)
+	if len(statuses) != 1 || statuses[0] != 500 || bodies[0] != `{"error":"internal server error"}` {
+		t.Fatalf("statuses = %v, bodies = %q", statuses, bodies)
+	}
+	if strings.Contains(out, "X-Partial") || !strings.Contains(out, "Connection: close") {
+		t.Fatalf("response = %q", out)
+	}
+	if got := Panics() - before; got != 1 {
+		t.Fatalf("Panics() grew by %d, want 1", got)
+	}
+}
+
+func TestPanicAfterHeaders(t *testing.T) {
+	before := Panics()
+	handler := HandlerFunc(func(w *ResponseWriter, r *Request) {
+		w.Write([]byte("partial"))
+		w.Flush()
+		panic("boom")
+	})
+
+	out := exchange(t, testConfig(), handler, "GET /a HTTP/1.1\r\nHost: x\r\n\r\nGET /b HTTP/1.1\r\nHost: x\r\n\r\n")
+	// заголовки и начало тела ушли, закрывающего чанка нет — клиент видит обрыв
+	if !strings.HasPrefix(out, "HTTP/1.1 200 ") || !strings.Contains(out, "Transfer-Encoding: chunked") {
+		t.Fatalf("response = %q", out)
+	}
+	if !strings.HasSuffix(out, "7\r\npartial\r\n") {
+		t.Fatalf("response not aborted after partial body: %q", out)
+	}
+	if strings.Count(out, "HTTP/1.1 ") != 1 {
+		t.Fatalf("connection reused after panic: %q", out)
+	}
+	if got := Panics() - before; got != 1 {
+		t.Fatalf("Panics() grew by %d, want 1", got)
+	}
+}
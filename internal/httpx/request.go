@@ -0,0 +1,525 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"web-server/internal/config"
+	"web-server/internal/model"
+)
+
+type Request struct {
+	Method   string
+	URL      *URL
+	Version  string
+	Body     []byte       // пустое для multipart/form-data
+	Uploads  []*UploadReq // файлы из multipart/form-data
+	FormData Values       // текстовые поля multipart/form-data
+	PostForm Values       // поля application/x-www-form-urlencoded
+	Headers  Header
+	Trailers Header            // поля после chunked-тела
+	Params   map[string]string // параметры пути, заполняет роутер
+
+	RemoteAddr string
+	TLS        *tls.ConnectionState // nil — соединение без TLS
+	Peer       *model.Identity      // клиент, опознанный по сертификату; заполняет middleware
+
+	ctx context.Context // отменяется при остановке сервера
+
+	// MaxBodyBytes — предел тела для этого запроса. Задаётся в Handler.Prepare
+	// до чтения тела, 0 — значение из конфига
+	MaxBodyBytes int64
+
+	// как читать тело — заполняется при разборе заголовков
+	contentLength    int
+	contentType      string
+	transferEncoding string
+	contentEncoding  []string // сжатие тела в порядке применения
+	forceClose       bool     // после ответа соединение закрыть
+}
+
+// Context отменяется, когда сервер начинает остановку. Нужен долгим
+// обработчикам — потокам событий, WebSocket, — чтобы вовремя завершиться
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// httpError — ошибка разбора запроса, для которой известен код ответа клиенту
+type httpError struct {
+	status int
+	msg    string
+	header Header // заголовки для ответа с ошибкой, например Allow или Accept-Encoding
+}
+
+func newHTTPError(status int, format string, args ...any) *httpError {
+	return &httpError{status: status, msg: fmt.Sprintf(format, args...)}
+}
+
+// withHeader добавляет заголовок в ответ с этой ошибкой
+func (e *httpError) withHeader(name, value string) *httpError {
+	if e.header == nil {
+		e.header = make(Header)
+	}
+	e.header.Add(name, value)
+	return e
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("%d: %s", e.status, e.msg)
+}
+
+var (
+	errLineTooLong = errors.New("line too long")
+	errBareLF      = errors.New("line not terminated by CRLF")
+)
+
+// Param возвращает параметр пути, например id из /users/{id}
+func (r *Request) Param(name string) string {
+	return r.Params[name]
+}
+
+// wantsKeepAlive определяет, хочет ли клиент оставить соединение открытым.
+// HTTP/1.1 держит соединение по умолчанию, HTTP/1.0 — только с Connection: keep-alive
+func (r *Request) wantsKeepAlive() bool {
+	if r.forceClose {
+		return false
+	}
+	switch r.Version {
+	case "HTTP/1.1":
+		return !r.Headers.HasToken("Connection", "close")
+	case "HTTP/1.0":
+		return r.Headers.HasToken("Connection", "keep-alive")
+	}
+	return false
+}
+
+// ExpectsContinue сообщает, ждёт ли клиент 100 Continue перед отправкой тела
+func (r *Request) ExpectsContinue() bool {
+	return r.Version == "HTTP/1.1" && strings.EqualFold(r.Headers.Get("Expect"), "100-continue")
+}
+
+// hasBody — у запроса есть тело по Content-Length или chunked
+func (r *Request) hasBody() bool {
+	return r.transferEncoding != "" || r.contentLength > 0
+}
+
+// ReadRequest читает один HTTP-запрос из reader и возвращает Request.
+// reader живёт всё время соединения, поэтому pipelined-запросы
+// остаются в буфере и читаются следующим вызовом
+func ReadRequest(reader *bufio.Reader, cfg *config.Config) (*Request, error) {
+	req, err := readHead(reader, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := readBody(reader, req, cfg.MaxBodyBytes, cfg); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// readHead читает строку запроса и заголовки и проверяет, можно ли
+// определить длину тела. Само тело не читается.
+// В строгом режиме (PARSER_MODE=strict) всё, что RFC 9112 разрешает
+// отвергать, отвергается: это защищает от request smuggling за прокси
+func readHead(reader *bufio.Reader, cfg *config.Config) (*Request, error) {
+	strict := cfg.StrictParsing
+
+	// Читаем первую строку запроса: Method Path Version
+	line, err := readLine(reader, cfg.MaxRequestLineBytes, strict)
+	if err == errLineTooLong {
+		return nil, newHTTPError(414, "request line exceeds %d bytes", cfg.MaxRequestLineBytes)
+	}
+	if err == errBareLF {
+		return nil, newHTTPError(400, "request line without CRLF")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request line: %w", err)
+	}
+
+	var parts []string
+	if strict {
+		// ровно три части через одиночный пробел
+		parts = strings.Split(line, " ")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, newHTTPError(400, "invalid request line: %q", line)
+		}
+	} else {
+		parts = strings.Fields(line)
+		if len(parts) < 3 {
+			return nil, newHTTPError(400, "invalid request line: %q", line)
+		}
+	}
+
+	if !validHeaderName(parts[0]) {
+		return nil, newHTTPError(400, "invalid method: %q", parts[0])
+	}
+	version, err := parseVersion(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	u, err := ParseRequestURI(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	req := &Request{
+		Method:  parts[0],
+		URL:     u,
+		Version: version,
+		Headers: make(Header),
+	}
+
+	// Читаем заголовки
+	headerBytes, headerCount := 0, 0
+	lastName := ""
+	for {
+		hline, err := readLine(reader, cfg.MaxHeaderBytes-headerBytes, strict)
+		if err == errLineTooLong {
+			return nil, newHTTPError(431, "headers exceed %d bytes", cfg.MaxHeaderBytes)
+		}
+		if err == errBareLF {
+			return nil, newHTTPError(400, "header line without CRLF")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read header line: %w", err)
+		}
+		if hline == "" {
+			break // конец заголовков
+		}
+		headerBytes += len(hline) + 2
+		headerCount++
+		if headerCount > cfg.MaxHeaderCount {
+			return nil, newHTTPError(431, "more than %d headers", cfg.MaxHeaderCount)
+		}
+
+		// obs-fold: продолжение предыдущего заголовка (RFC 9112, 5.2)
+		if hline[0] == ' ' || hline[0] == '\t' {
+			if strict || lastName == "" {
+				return nil, newHTTPError(400, "obsolete line folding")
+			}
+			values := req.Headers[lastName]
+			values[len(values)-1] += " " + trimOWS(hline)
+			continue
+		}
+
+		name, value, ok := strings.Cut(hline, ":")
+		if !strict {
+			name = strings.TrimRight(name, " \t")
+		}
+		if !ok || !validHeaderName(name) {
+			// пробел перед двоеточием — классический вектор smuggling (RFC 9112, 5.1)
+			if strict {
+				return nil, newHTTPError(400, "invalid header line: %q", hline)
+			}
+			lastName = ""
+			continue
+		}
+		value = trimOWS(value)
+		if !validHeaderValue(value, strict) {
+			return nil, newHTTPError(400, "invalid value of header %q", name)
+		}
+
+		lastName = CanonicalHeaderKey(name)
+		req.Headers.Add(name, value) // <-- сохраняем заголовок
+	}
+
+	if err := checkFraming(req, strict); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// checkFraming по заголовкам определяет, как читать тело,
+// и отвергает неоднозначные сообщения
+func checkFraming(req *Request, strict bool) error {
+	if strict {
+		// HTTP/1.1 требует ровно один Host (RFC 9112, 3.2)
+		hosts := len(req.Headers.Values("Host"))
+		if hosts > 1 || (hosts == 0 && req.Version == "HTTP/1.1") {
+			return newHTTPError(400, "request must contain exactly one Host header")
+		}
+	}
+
+	contentLength, err := parseContentLength(req.Headers.Values("Content-Length"))
+	if err != nil {
+		return err
+	}
+	transferEncoding := strings.Join(req.Headers.Values("Transfer-Encoding"), ", ")
+
+	// Transfer-Encoding важнее Content-Length (RFC 9112, 6.3)
+	switch {
+	case transferEncoding != "":
+		if contentLength != -1 || req.Version == "HTTP/1.0" {
+			if strict {
+				return newHTTPError(400, "transfer-encoding with content-length or in HTTP/1.0")
+			}
+			// нестрогий режим: верим Transfer-Encoding, но соединение
+			// после ответа закрываем — граница следующего запроса сомнительна
+			req.forceClose = true
+			req.Headers.Del("Content-Length")
+		}
+		if err := checkTransferEncoding(transferEncoding); err != nil {
+			return err
+		}
+		contentLength = -1
+	case contentLength == -1:
+		// без длины тело у POST/PUT прочитать нельзя
+		if req.Method == "POST" || req.Method == "PUT" || req.Method == "PATCH" {
+			return newHTTPError(411, "length required for %s", req.Method)
+		}
+	}
+
+	req.contentLength = contentLength
+	req.contentType = req.Headers.Get("Content-Type")
+	req.transferEncoding = transferEncoding
+	if req.hasBody() {
+		// неизвестное сжатие отвергаем до 100 Continue, тело не нужно
+		if req.contentEncoding, err = parseContentEncoding(req.Headers.Values("Content-Encoding")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseContentLength разбирает все значения Content-Length. Повторы
+// допустимы, только если совпадают (RFC 9110, 8.6). -1 — заголовка нет
+func parseContentLength(values []string) (int, error) {
+	length := -1
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			item = trimOWS(item)
+			if item == "" || strings.Trim(item, "0123456789") != "" || len(item) > 18 {
+				return 0, newHTTPError(400, "invalid content-length: %q", v)
+			}
+			n, err := strconv.Atoi(item)
+			if err != nil {
+				return 0, newHTTPError(400, "invalid content-length: %q", v)
+			}
+			if length != -1 && n != length {
+				return 0, newHTTPError(400, "conflicting content-length values: %q", values)
+			}
+			length = n
+		}
+	}
+	return length, nil
+}
+
+// parseVersion проверяет HTTP-version. Поддерживается только HTTP/1.x,
+// младшие версии новее 1.1 обслуживаются как HTTP/1.1
+func parseVersion(v string) (string, error) {
+	if len(v) != 8 || !strings.HasPrefix(v, "HTTP/") || v[6] != '.' ||
+		!isDigit(v[5]) || !isDigit(v[7]) {
+		return "", newHTTPError(400, "invalid HTTP version: %q", v)
+	}
+	if v[5] != '1' {
+		return "", newHTTPError(505, "unsupported HTTP version: %q", v)
+	}
+	if v[7] == '0' {
+		return "HTTP/1.0", nil
+	}
+	return "HTTP/1.1", nil
+}
+
+// readLine читает строку до \n, не длиннее max байт, и убирает конец строки.
+// В отличие от ReadString не даёт клиенту забить память бесконечной строкой.
+// strict требует CRLF и запрещает одиночные CR внутри строки
+func readLine(reader *bufio.Reader, max int, strict bool) (string, error) {
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(line)+len(chunk) > max {
+			return "", errLineTooLong
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		break
+	}
+
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	} else if strict {
+		return "", errBareLF
+	}
+	if strict && bytes.IndexByte(line, '\r') != -1 {
+		return "", errBareLF
+	}
+	return string(line), nil
+}
+
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isTokenChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// validHeaderValue запрещает управляющие символы. В нестрогом режиме
+// терпим всё, кроме NUL, CR и LF
+func validHeaderValue(v string, strict bool) bool {
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c == 0 || c == '\r' || c == '\n' {
+			return false
+		}
+		if strict && ((c < ' ' && c != '\t') || c == 0x7f) {
+			return false
+		}
+	}
+	return true
+}
+
+// trimOWS убирает пробелы и табы по краям (OWS в RFC 9110)
+func trimOWS(s string) string {
+	return strings.Trim(s, " \t")
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// readBody читает тело запроса согласно заголовкам, разобранным readHead.
+// Тело больше limit байт отклоняется с 413 до выделения памяти.
+// multipart/form-data разбирается потоково и в req.Body не попадает
+func readBody(reader *bufio.Reader, req *Request, limit int64, cfg *config.Config) (err error) {
+	defer func() {
+		// запрос с ошибкой до обработчика не дойдёт, и загрузки никто не удалит
+		if err != nil {
+			req.removeUploads()
+		}
+	}()
+	var body io.Reader
+	switch {
+	case req.transferEncoding != "":
+		body = newChunkedReader(reader, req, limit, cfg.StrictParsing)
+	case int64(req.contentLength) > limit:
+		return newHTTPError(413, "content-length %d exceeds %d bytes", req.contentLength, limit)
+	case req.contentLength > 0:
+		body = &fixedReader{r: reader, n: int64(req.contentLength)}
+	default:
+		return nil
+	}
+	if len(req.contentEncoding) > 0 {
+		// обработчик получает уже распакованное тело
+		framed := body
+		decoder := newBodyDecoder(framed, req.contentEncoding, limit)
+		body = decoder
+		req.Headers.Del("Content-Encoding")
+		defer func() {
+			// хвост после конца сжатого потока — чтобы не сбить следующий запрос
+			if err == nil {
+				_, err = io.Copy(io.Discard, framed)
+			}
+			// длина на проводе к распакованному телу не относится
+			req.Headers.Set("Content-Length", strconv.FormatInt(decoder.decoded(), 10))
+		}()
+	}
+
+	boundary, isMultipart, err := multipartBoundary(req.contentType)
+	if err != nil {
+		return err
+	}
+	if isMultipart {
+		if err := parseMultipart(req, body, boundary, multipartLimitsFromConfig(cfg)); err != nil {
+			return err
+		}
+		// остаток тела дочитываем, чтобы не сбить следующий запрос
+		_, err := io.Copy(io.Discard, body)
+		return err
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+	req.Body = data
+
+	if isURLEncodedForm(req.contentType) {
+		form, err := ParseQuery(string(data))
+		if err != nil {
+			return newHTTPError(400, "invalid form body: %v", err)
+		}
+		req.PostForm = form
+	}
+
+	return nil
+}
+
+// fixedReader читает ровно n байт тела по Content-Length.
+// Если соединение закрылось раньше — io.ErrUnexpectedEOF
+type fixedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (fr *fixedReader) Read(p []byte) (int, error) {
+	if fr.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > fr.n {
+		p = p[:fr.n]
+	}
+	n, err := fr.r.Read(p)
+	fr.n -= int64(n)
+	if err == io.EOF && fr.n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// FormValue возвращает первое значение поля формы. Ищет по очереди
+// в urlencoded-теле, в текстовых полях multipart и в query-строке
+func (r *Request) FormValue(key string) string {
+	if vs := r.FormValues(key); len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+// FormValues возвращает все значения поля из первого источника,
+// где оно есть, в том же порядке, что и FormValue
+func (r *Request) FormValues(key string) []string {
+	sources := []Values{r.PostForm, r.FormData}
+	if r.URL != nil {
+		sources = append(sources, r.URL.Query)
+	}
+	for _, src := range sources {
+		if vs, ok := src[key]; ok {
+			return vs
+		}
+	}
+	return nil
+}
+
+// isURLEncodedForm проверяет Content-Type без учёта параметров (charset)
+func isURLEncodedForm(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.EqualFold(strings.TrimSpace(mediaType), "application/x-www-form-urlencoded")
+}
+
+// removeUploads удаляет временные файлы загрузок. Вызывается после
+// обработки запроса
+func (r *Request) removeUploads() {
+	for _, u := range r.Uploads {
+		u.remove()
+	}
+}
@@ -0,0 +1,113 @@
+package httpx
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// parseRequest разбирает заголовки и тело одного запроса из raw
+func parseRequest(t *testing.T, raw string) *Request {
+	t.Helper()
+	cfg := testConfig()
+	cfg.UploadTempDir = t.TempDir()
+	reader := bufio.NewReader(strings.NewReader(raw))
+	req, err := readHead(reader, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := readBody(reader, req, cfg.MaxBodyBytes, cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(req.removeUploads)
+	return req
+}
+
+func formRequest(target, contentType, body string) string {
+	return fmt.Sprintf("POST %s HTTP/1.1\r\nHost: x\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n%s",
+		target, contentType, len(body), body)
+}
+
+func TestFormPrecedence(t *testing.T) {
+	multipart := "multipart/form-data; boundary=" + testBoundary
+	tests := []struct {
+		name     string
+		raw      string
+		key      string
+		value    string   // FormValue
+		values   []string // FormValues
+		postForm string   // PostForm.Get
+	}{
+		{name: "urlencoded body over query",
+			raw: formRequest("/a?role=query&page=2", "application/x-www-form-urlencoded", "role=body&role=second"),
+			key: "role", value: "body", values: []string{"body", "second"}, postForm: "body"},
+		{name: "query when body lacks key",
+			raw: formRequest("/a?role=query&page=2", "application/x-www-form-urlencoded", "role=body"),
+			key: "page", value: "2", values: []string{"2"}},
+		{name: "empty body value still wins",
+			raw: formRequest("/a?role=query", "application/x-www-form-urlencoded", "role="),
+			key: "role", value: "", values: []string{""}},
+		{name: "multipart field over query",
+			raw: formRequest("/a?role=query", multipart, multipartBody(fieldPart("role", "part"))),
+			key: "role", value: "part", values: []string{"part"}},
+		// PostForm — только urlencoded-тело, поля multipart в FormData
+		{name: "multipart not in post form",
+			raw: formRequest("/a", multipart, multipartBody(fieldPart("role", "part"))),
+			key: "role", value: "part", values: []string{"part"}, postForm: ""},
+		// файл — не поле формы
+		{name: "multipart file is not a value",
+			raw: formRequest("/a?avatar=query", multipart, multipartBody(filePart("avatar", "a.png", "png"))),
+			key: "avatar", value: "query", values: []string{"query"}},
+		// тело другого типа формой не считается
+		{name: "json body ignored",
+			raw: formRequest("/a?role=query", "application/json", `{"role":"json"}`),
+			key: "role", value: "query", values: []string{"query"}},
+		{name: "missing everywhere",
+			raw: formRequest("/a?x=1", "application/x-www-form-urlencoded", "y=2"),
+			key: "role", value: "", values: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := parseRequest(t, tt.raw)
+			if got := req.FormValue(tt.key); got != tt.value {
+				t.Errorf("FormValue(%q) = %q, want %q", tt.key, got, tt.value)
+			}
+			if got := req.FormValues(tt.key); fmt.Sprint(got) != fmt.Sprint(tt.values) {
+				t.Errorf("FormValues(%q) = %q, want %q", tt.key, got, tt.values)
+			}
+			if got := req.PostForm.Get(tt.key); got != tt.postForm {
+				t.Errorf("PostForm.Get(%q) = %q, want %q", tt.key, got, tt.postForm)
+			}
+		})
+	}
+
+	// одно тело не бывает и urlencoded, и multipart, но порядок
+	// источников фиксирован: urlencoded, затем multipart, затем query
+	req := &Request{
+		PostForm: Values{"role": {"urlencoded"}},
+		FormData: Values{"role": {"multipart"}, "page": {"multipart"}},
+		URL:      &URL{Query: Values{"role": {"query"}, "page": {"query"}}},
+	}
+	if got := req.FormValue("role"); got != "urlencoded" {
+		t.Errorf("role = %q, want urlencoded", got)
+	}
+	if got := req.FormValue("page"); got != "multipart" {
+		t.Errorf("page = %q, want multipart", got)
+	}
+}
+
+func TestRedactedHeaders(t *testing.T) {
+	h := Header{}
+	h.Set("Authorization", "Bearer secret")
+	h.Set("cookie", "session=secret")
+	h.Set("Content-Type", "application/json")
+
+	r := h.redacted()
+	if got := fmt.Sprint(r); strings.Contains(got, "secret") || r.Get("Content-Type") != "application/json" {
+		t.Fatalf("redacted = %v", got)
+	}
+	if h.Get("Authorization") != "Bearer secret" {
+		t.Fatal("redacted changed the original headers")
+	}
+}
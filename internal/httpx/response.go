@@ -0,0 +1,412 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// bufferSize — сколько байт тела копится в памяти, прежде чем
+// ответ переключится на потоковую отправку
+const bufferSize = 32 * 1024
+
+var statusText = map[int]string{
+	100: "Continue",
+	101: "Switching Protocols",
+	102: "Processing",
+	103: "Early Hints",
+
+	200: "OK",
+	201: "Created",
+	202: "Accepted",
+	203: "Non-Authoritative Information",
+	204: "No Content",
+	205: "Reset Content",
+	206: "Partial Content",
+	207: "Multi-Status",
+	208: "Already Reported",
+	226: "IM Used",
+
+	300: "Multiple Choices",
+	301: "Moved Permanently",
+	302: "Found",
+	303: "See Other",
+	304: "Not Modified",
+	305: "Use Proxy",
+	307: "Temporary Redirect",
+	308: "Permanent Redirect",
+
+	400: "Bad Request",
+	401: "Unauthorized",
+	402: "Payment Required",
+	403: "Forbidden",
+	404: "Not Found",
+	405: "Method Not Allowed",
+	406: "Not Acceptable",
+	407: "Proxy Authentication Required",
+	408: "Request Timeout",
+	409: "Conflict",
+	410: "Gone",
+	411: "Length Required",
+	412: "Precondition Failed",
+	413: "Content Too Large",
+	414: "URI Too Long",
+	415: "Unsupported Media Type",
+	416: "Range Not Satisfiable",
+	417: "Expectation Failed",
+	418: "I'm a teapot",
+	421: "Misdirected Request",
+	422: "Unprocessable Content",
+	423: "Locked",
+	424: "Failed Dependency",
+	425: "Too Early",
+	426: "Upgrade Required",
+	428: "Precondition Required",
+	429: "Too Many Requests",
+	431: "Request Header Fields Too Large",
+	451: "Unavailable For Legal Reasons",
+
+	500: "Internal Server Error",
+	501: "Not Implemented",
+	502: "Bad Gateway",
+	503: "Service Unavailable",
+	504: "Gateway Timeout",
+	505: "HTTP Version Not Supported",
+	506: "Variant Also Negotiates",
+	507: "Insufficient Storage",
+	508: "Loop Detected",
+	510: "Not Extended",
+	511: "Network Authentication Required",
+}
+
+// StatusText возвращает reason phrase для кода ответа
+func StatusText(code int) string {
+	if text, ok := statusText[code]; ok {
+		return text
+	}
+	return "Unknown"
+}
+
+// ResponseWriter собирает ответ на один запрос.
+// Пока тело помещается в буфер, ответ уходит целиком с Content-Length.
+// После Flush или переполнения буфера заголовки отправляются сразу,
+// а тело идёт чанками (HTTP/1.1) или до закрытия соединения (HTTP/1.0)
+type ResponseWriter struct {
+	w       *bufio.Writer
+	header  Header
+	status  int
+	body    []byte
+	noBody  bool // HEAD-запрос — тело не отправляем
+	version string
+
+	wroteHeader bool // статус выбран
+	sentHeader  bool // заголовки уже ушли в сокет
+	chunked     bool
+	keepAlive   bool
+	written     int64 // байт тела до сжатия
+
+	compress *compression // nil — middleware сжатия не подключён
+	encoder  encoder      // потоковый ответ идёт через компрессор
+
+	// соединение для Hijack, nil — перехват невозможен (ответ на ошибку разбора)
+	conn     net.Conn
+	reader   *bufio.Reader
+	hijacked bool
+	aborted  bool // паника после отправки заголовков — ответ не завершать
+}
+
+// newResponseWriter создаёт writer для ответа на req.
+// req может быть nil, если запрос не удалось разобрать
+func newResponseWriter(w io.Writer, req *Request, keepAlive bool) *ResponseWriter {
+	rw := &ResponseWriter{
+		w:         bufio.NewWriter(w),
+		header:    make(Header),
+		version:   "HTTP/1.1",
+		keepAlive: keepAlive,
+	}
+	if req != nil {
+		rw.noBody = req.Method == "HEAD"
+		if req.Version == "HTTP/1.0" {
+			rw.version = req.Version
+		}
+	}
+	return rw
+}
+
+// Header возвращает заголовки ответа. Менять их можно до отправки заголовков
+func (rw *ResponseWriter) Header() Header {
+	return rw.header
+}
+
+// WriteHeader задаёт код ответа. Повторные вызовы игнорируются
+func (rw *ResponseWriter) WriteHeader(status int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+	rw.status = status
+}
+
+// Status возвращает выбранный код ответа
+func (rw *ResponseWriter) Status() int {
+	return rw.status
+}
+
+// Written возвращает число байт тела, переданных через Write
+func (rw *ResponseWriter) Written() int64 {
+	return rw.written
+}
+
+// HeaderSent сообщает, ушли ли заголовки клиенту. После этого
+// менять статус и заголовки уже нельзя
+func (rw *ResponseWriter) HeaderSent() bool {
+	return rw.sentHeader
+}
+
+// Reset сбрасывает статус, заголовки и буфер тела, если ответ ещё
+// не начал отправляться. Возвращает false, если сбросить уже поздно
+func (rw *ResponseWriter) Reset() bool {
+	if rw.sentHeader {
+		return false
+	}
+	rw.header = make(Header)
+	rw.status = 0
+	rw.wroteHeader = false
+	rw.body = nil
+	rw.written = 0
+	return true
+}
+
+// KeepAlive сообщает, можно ли читать следующий запрос из соединения
+func (rw *ResponseWriter) KeepAlive() bool {
+	return rw.keepAlive
+}
+
+// Hijack передаёт соединение обработчику — для протоколов поверх HTTP,
+// например WebSocket. Если статус уже задан (101), заголовки ответа
+// отправляются как есть, без Connection от keep-alive. Дальше ResponseWriter
+// в соединение не пишет, а ServeConn закроет его, когда обработчик вернётся.
+// reader может содержать уже полученные от клиента байты
+func (rw *ResponseWriter) Hijack() (net.Conn, *bufio.Reader, error) {
+	if rw.conn == nil {
+		return nil, nil, errors.New("httpx: connection cannot be hijacked")
+	}
+	if rw.hijacked || rw.sentHeader || len(rw.body) > 0 {
+		return nil, nil, errors.New("httpx: response already started")
+	}
+	rw.hijacked = true
+	rw.sentHeader = true
+	rw.keepAlive = false
+	if rw.wroteHeader {
+		if err := rw.writeHead(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := rw.w.Flush(); err != nil {
+		return nil, nil, err
+	}
+	return rw.conn, rw.reader, nil
+}
+
+// Write добавляет данные в тело ответа
+func (rw *ResponseWriter) Write(p []byte) (int, error) {
+	rw.WriteHeader(200)
+	if !bodyAllowed(rw.status) {
+		return 0, fmt.Errorf("response with status %d cannot have a body", rw.status)
+	}
+	rw.written += int64(len(p))
+	if rw.noBody {
+		return len(p), nil
+	}
+
+	if !rw.sentHeader {
+		rw.body = append(rw.body, p...)
+		if len(rw.body) >= bufferSize {
+			if err := rw.Flush(); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	if err := rw.writeData(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush отправляет заголовки и накопленное тело клиенту
+func (rw *ResponseWriter) Flush() error {
+	rw.WriteHeader(200)
+	if !rw.sentHeader {
+		if rw.startCompression(-1) {
+			rw.encoder = newEncoder(rw.compress.encoding, bodySink{rw})
+		}
+		if !rw.header.Has("Content-Length") && bodyAllowed(rw.status) && !rw.noBody {
+			if rw.version == "HTTP/1.1" {
+				rw.chunked = true
+			} else {
+				// HTTP/1.0 не знает chunked — конец тела обозначит закрытие соединения
+				rw.keepAlive = false
+			}
+		}
+		if err := rw.writeHeader(); err != nil {
+			return err
+		}
+		body := rw.body
+		rw.body = nil
+		if err := rw.writeData(body); err != nil {
+			return err
+		}
+	}
+	if rw.encoder != nil {
+		// отдаём клиенту всё, что компрессор успел накопить
+		if err := rw.encoder.Flush(); err != nil {
+			return err
+		}
+	}
+	return rw.w.Flush()
+}
+
+// finish завершает ответ: отправляет буферизованный ответ целиком
+// или закрывающий чанк для потокового. Прерванный паникой ответ
+// не завершается
+func (rw *ResponseWriter) finish() error {
+	if rw.hijacked || rw.aborted {
+		return nil
+	}
+	rw.WriteHeader(200)
+	if !rw.sentHeader {
+		body := rw.body
+		rw.body = nil
+		if rw.startCompression(rw.bodySize()) {
+			// у HEAD тела нет, и сжатый размер неизвестен — Content-Length
+			// не отправляем, как GET с потоковым ответом
+			if !rw.noBody {
+				// тело целиком в памяти — сжимаем сразу и отдаём с Content-Length
+				var buf bytes.Buffer
+				enc := newEncoder(rw.compress.encoding, &buf)
+				enc.Write(body)
+				if err := enc.Close(); err != nil {
+					return err
+				}
+				body = buf.Bytes()
+				rw.header.Set("Content-Length", strconv.Itoa(len(body)))
+			}
+		} else if !rw.header.Has("Content-Length") && bodyAllowed(rw.status) {
+			rw.header.Set("Content-Length", strconv.FormatInt(rw.written, 10))
+		}
+		if err := rw.writeHeader(); err != nil {
+			return err
+		}
+		if err := rw.writeBody(body); err != nil {
+			return err
+		}
+		return rw.w.Flush()
+	}
+
+	if rw.encoder != nil {
+		// Close дописывает хвост сжатого потока
+		err := rw.encoder.Close()
+		rw.encoder = nil
+		if err != nil {
+			return err
+		}
+	}
+	if rw.chunked {
+		if _, err := io.WriteString(rw.w, "0\r\n\r\n"); err != nil {
+			return err
+		}
+	}
+	return rw.w.Flush()
+}
+
+// bodySize — размер тела для решения о сжатии. Content-Length от
+// обработчика важнее: на HEAD он тело не пишет, но решение должно
+// совпасть с GET
+func (rw *ResponseWriter) bodySize() int64 {
+	if n, err := strconv.ParseInt(rw.header.Get("Content-Length"), 10, 64); err == nil && n >= 0 {
+		return n
+	}
+	return rw.written
+}
+
+func (rw *ResponseWriter) writeHeader() error {
+	rw.sentHeader = true
+
+	if strings.EqualFold(rw.header.Get("Connection"), "close") {
+		rw.keepAlive = false
+	}
+	rw.header.Set("Connection", connectionHeader(rw.keepAlive))
+	if rw.chunked {
+		rw.header.Del("Content-Length")
+		rw.header.Set("Transfer-Encoding", "chunked")
+	}
+	if !bodyAllowed(rw.status) {
+		rw.header.Del("Content-Length")
+		rw.header.Del("Transfer-Encoding")
+	}
+	return rw.writeHead()
+}
+
+// writeHead пишет строку статуса и заголовки как есть
+func (rw *ResponseWriter) writeHead() error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %d %s\r\n", rw.version, rw.status, StatusText(rw.status))
+
+	// сортируем, чтобы порядок заголовков был стабильным
+	for _, name := range rw.header.sortedKeys() {
+		for _, v := range rw.header[name] {
+			fmt.Fprintf(&sb, "%s: %s\r\n", name, v)
+		}
+	}
+	sb.WriteString("\r\n")
+
+	_, err := io.WriteString(rw.w, sb.String())
+	return err
+}
+
+// writeData пишет данные тела после отправки заголовков, через компрессор,
+// если он включён
+func (rw *ResponseWriter) writeData(p []byte) error {
+	if rw.encoder != nil {
+		_, err := rw.encoder.Write(p)
+		return err
+	}
+	return rw.writeBody(p)
+}
+
+func (rw *ResponseWriter) writeBody(p []byte) error {
+	if len(p) == 0 || rw.noBody || !bodyAllowed(rw.status) {
+		return nil
+	}
+	if !rw.chunked {
+		_, err := rw.w.Write(p)
+		return err
+	}
+	if _, err := fmt.Fprintf(rw.w, "%x\r\n", len(p)); err != nil {
+		return err
+	}
+	if _, err := rw.w.Write(p); err != nil {
+		return err
+	}
+	_, err := io.WriteString(rw.w, "\r\n")
+	return err
+}
+
+// connectionHeader возвращает значение заголовка Connection для ответа
+func connectionHeader(keepAlive bool) string {
+	if keepAlive {
+		return "keep-alive"
+	}
+	return "close"
+}
+
+// bodyAllowed — у 1xx, 204 и 304 тела быть не может
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != 204 && status != 304
+}
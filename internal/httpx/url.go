@@ -0,0 +1,286 @@
+package httpx
+
+import (
+	"sort"
+	"strings"
+)
+
+// URL — разобранная цель запроса (request-target)
+type URL struct {
+	Path     string // декодированный путь: /users/team lead
+	RawPath  string // путь как прислал клиент: /users/team%20lead
+	RawQuery string // всё после '?', без декодирования
+	Query    Values
+
+	cleaned bool // путь изменился при нормализации
+}
+
+// String собирает URL обратно в форму request-target
+func (u *URL) String() string {
+	if u.RawQuery == "" {
+		return u.RawPath
+	}
+	return u.RawPath + "?" + u.RawQuery
+}
+
+// Values — параметры query-строки или формы. Ключ может повторяться
+type Values map[string][]string
+
+// Get возвращает первое значение параметра или ""
+func (v Values) Get(key string) string {
+	if vs := v[key]; len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+// All возвращает все значения параметра в порядке появления
+func (v Values) All(key string) []string {
+	return v[key]
+}
+
+// Has сообщает, был ли параметр, даже без значения (?debug)
+func (v Values) Has(key string) bool {
+	_, ok := v[key]
+	return ok
+}
+
+// Add добавляет значение к параметру
+func (v Values) Add(key, value string) {
+	v[key] = append(v[key], value)
+}
+
+// Set заменяет все значения параметра одним
+func (v Values) Set(key, value string) {
+	v[key] = []string{value}
+}
+
+// Encode собирает параметры в строку a=1&b=2, ключи по алфавиту
+func (v Values) Encode() string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		for _, val := range v[k] {
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(QueryEscape(k))
+			sb.WriteByte('=')
+			sb.WriteString(QueryEscape(val))
+		}
+	}
+	return sb.String()
+}
+
+// ParseRequestURI разбирает request-target из строки запроса.
+// Поддерживаются origin-form (/path?q) и absolute-form (http://host/path?q),
+// а также "*" для OPTIONS
+func ParseRequestURI(target string) (*URL, error) {
+	if target == "" {
+		return nil, newHTTPError(400, "empty request target")
+	}
+	if target == "*" {
+		return &URL{Path: "*", RawPath: "*", Query: Values{}}, nil
+	}
+
+	// absolute-form: отбрасываем схему и authority
+	if i := strings.Index(target, "://"); i > 0 && !strings.HasPrefix(target, "/") {
+		rest := target[i+3:]
+		if j := strings.IndexAny(rest, "/?"); j != -1 {
+			target = rest[j:]
+		} else {
+			target = "/"
+		}
+		if strings.HasPrefix(target, "?") {
+			target = "/" + target
+		}
+	}
+	if !strings.HasPrefix(target, "/") {
+		return nil, newHTTPError(400, "invalid request target: %q", target)
+	}
+
+	u := &URL{RawPath: target}
+	if i := strings.IndexByte(target, '?'); i != -1 {
+		u.RawPath, u.RawQuery = target[:i], target[i+1:]
+	}
+	if i := strings.IndexByte(u.RawQuery, '#'); i != -1 {
+		u.RawQuery = u.RawQuery[:i]
+	}
+
+	if err := checkEncodedTraversal(u.RawPath); err != nil {
+		return nil, err
+	}
+	clean := CleanPath(u.RawPath)
+	u.cleaned = clean != u.RawPath
+	u.RawPath = clean
+
+	path, err := unescape(u.RawPath, false)
+	if err != nil {
+		return nil, newHTTPError(400, "invalid path: %v", err)
+	}
+	u.Path = path
+
+	if u.Query, err = ParseQuery(u.RawQuery); err != nil {
+		return nil, newHTTPError(400, "invalid query: %v", err)
+	}
+	return u, nil
+}
+
+// CleanPath приводит путь к каноническому виду: убирает повторные слеши
+// и dot-сегменты (RFC 3986, 5.2.4). Выше корня подняться нельзя.
+// Завершающий слеш сохраняется — для каталогов он значим
+func CleanPath(p string) string {
+	if p == "" || p == "*" {
+		return p
+	}
+	trailing := strings.HasSuffix(p, "/") || strings.HasSuffix(p, "/.") || strings.HasSuffix(p, "/..")
+
+	var out []string
+	for _, seg := range strings.Split(p, "/") {
+		switch seg {
+		case "", ".":
+			// пустые сегменты от "//" и "." просто пропускаем
+		case "..":
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+
+	clean := "/" + strings.Join(out, "/")
+	if trailing && clean != "/" {
+		clean += "/"
+	}
+	return clean
+}
+
+// checkEncodedTraversal отклоняет пути, где слеши или dot-сегменты
+// закодированы (%2e%2e%2f, %2F, %5C): после декодирования они обошли бы
+// нормализацию и вывели за пределы корня
+func checkEncodedTraversal(rawPath string) error {
+	for _, seg := range strings.Split(rawPath, "/") {
+		if !strings.Contains(seg, "%") {
+			continue
+		}
+		dec, err := unescape(seg, false)
+		if err != nil {
+			return newHTTPError(400, "invalid path: %v", err)
+		}
+		if dec == "." || dec == ".." || strings.ContainsAny(dec, "/\\\x00") {
+			return newHTTPError(400, "encoded traversal in path: %q", rawPath)
+		}
+	}
+	return nil
+}
+
+// ParseQuery разбирает строку вида a=1&b=2&b=3&flag.
+// '+' означает пробел, %XX декодируются, ключ без '=' получает пустое значение
+func ParseQuery(raw string) (Values, error) {
+	values := Values{}
+	for _, pair := range strings.Split(raw, "&") {
+		if pair == "" {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		key, err := unescape(rawKey, true)
+		if err != nil {
+			return nil, err
+		}
+		value, err := unescape(rawValue, true)
+		if err != nil {
+			return nil, err
+		}
+		values.Add(key, value)
+	}
+	return values, nil
+}
+
+// PathUnescape декодирует %XX в сегменте пути. '+' остаётся плюсом
+func PathUnescape(s string) (string, error) {
+	return unescape(s, false)
+}
+
+// QueryUnescape декодирует %XX и '+' как пробел
+func QueryUnescape(s string) (string, error) {
+	return unescape(s, true)
+}
+
+// QueryEscape кодирует строку для query: всё кроме unreserved (RFC 3986, 2.3)
+// превращается в %XX, пробел — в '+'
+func QueryEscape(s string) string {
+	const hex = "0123456789ABCDEF"
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isUnreserved(c):
+			sb.WriteByte(c)
+		case c == ' ':
+			sb.WriteByte('+')
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(hex[c>>4])
+			sb.WriteByte(hex[c&15])
+		}
+	}
+	return sb.String()
+}
+
+type escapeError string
+
+func (e escapeError) Error() string {
+	return "invalid URL escape " + string(e)
+}
+
+// unescape декодирует percent-encoding (RFC 3986, 2.1).
+// Обрезанные или не-hex последовательности — ошибка
+func unescape(s string, plusAsSpace bool) (string, error) {
+	if !strings.ContainsAny(s, "%+") {
+		return s, nil
+	}
+
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				end := min(i+3, len(s))
+				return "", escapeError(s[i:end])
+			}
+			b = append(b, unhex(s[i+1])<<4|unhex(s[i+2]))
+			i += 2
+		case c == '+' && plusAsSpace:
+			b = append(b, ' ')
+		default:
+			b = append(b, c)
+		}
+	}
+	return string(b), nil
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
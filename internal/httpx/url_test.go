@@ -0,0 +1,121 @@
+package httpx
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", "/"},
+		{"", ""},
+		{"*", "*"},
+		{"/users", "/users"},
+		{"/users/", "/users/"},
+		// dot-сегменты
+		{"/a/./b", "/a/b"},
+		{"/a/../b", "/b"},
+		{"/a/b/..", "/a/"},
+		{"/a/b/.", "/a/b/"},
+		{"/..", "/"},
+		{"/../../etc/passwd", "/etc/passwd"},
+		{"/a/.../b", "/a/.../b"},
+		{"/a/..b/c", "/a/..b/c"},
+		// повторные слеши
+		{"//a", "/a"},
+		{"/a//b///c", "/a/b/c"},
+		{"/a//", "/a/"},
+		{"///", "/"},
+		// закодированное CleanPath не декодирует — это делает checkEncodedTraversal
+		{"/a%2Fb", "/a%2Fb"},
+		{"/a/%2e%2e/b", "/a/%2e%2e/b"},
+		{"/a%20b/./c", "/a%20b/c"},
+	}
+	for _, tt := range tests {
+		if got := CleanPath(tt.path); got != tt.want {
+			t.Errorf("CleanPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseRequestURIPath(t *testing.T) {
+	tests := []struct {
+		target  string
+		path    string // декодированный путь
+		cleaned bool
+		status  int // ожидаемая ошибка, 0 — разбирается
+	}{
+		{target: "/a/b?x=1", path: "/a/b"},
+		{target: "/a//b/../c", path: "/a/c", cleaned: true},
+		{target: "/team%20lead", path: "/team lead"},
+		{target: "/a/%2e%2e/b", status: 400},
+		{target: "/a/%2E/b", status: 400},
+		{target: "/a%2Fb", status: 400},
+		{target: "/a%2fb", status: 400},
+		{target: "/a%5Cb", status: 400},
+		{target: "/a%00b", status: 400},
+		{target: "/a%zz", status: 400},
+	}
+	for _, tt := range tests {
+		u, err := ParseRequestURI(tt.target)
+		if tt.status != 0 {
+			var he *httpError
+			if !errors.As(err, &he) || he.status != tt.status {
+				t.Errorf("ParseRequestURI(%q) error = %v, want status %d", tt.target, err, tt.status)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRequestURI(%q): %v", tt.target, err)
+			continue
+		}
+		if u.Path != tt.path || u.cleaned != tt.cleaned {
+			t.Errorf("ParseRequestURI(%q) = %q cleaned=%v, want %q cleaned=%v", tt.target, u.Path, u.cleaned, tt.path, tt.cleaned)
+		}
+	}
+}
+
+func TestPathRedirect(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   int // PathRedirectStatus
+		raw      string
+		status   int
+		location string
+	}{
+		{name: "get 301", config: 301, raw: "GET /a//b/../c?x=1 HTTP/1.1\r\nHost: x\r\n\r\n",
+			status: 301, location: "/a/c?x=1"},
+		{name: "head 301", config: 301, raw: "HEAD /a/./b HTTP/1.1\r\nHost: x\r\n\r\n",
+			status: 301, location: "/a/b"},
+		{name: "get 308", config: 308, raw: "GET //a HTTP/1.1\r\nHost: x\r\n\r\n",
+			status: 308, location: "/a"},
+		// 301 превратил бы POST в GET — для остальных методов всегда 308
+		{name: "post forced 308", config: 301, raw: "POST /a//b HTTP/1.1\r\nHost: x\r\nContent-Length: 2\r\n\r\nhi",
+			status: 308, location: "/a/b"},
+		{name: "delete forced 308", config: 301, raw: "DELETE /a/../b HTTP/1.1\r\nHost: x\r\n\r\n",
+			status: 308, location: "/b"},
+		{name: "canonical path served", config: 301, raw: "GET /a/b HTTP/1.1\r\nHost: x\r\nConnection: close\r\n\r\n",
+			status: 200},
+		{name: "redirect disabled", config: 0, raw: "GET /a//b HTTP/1.1\r\nHost: x\r\nConnection: close\r\n\r\n",
+			status: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.PathRedirectStatus = tt.config
+			out := exchange(t, cfg, echoHandler, tt.raw)
+			statuses, _ := responses(t, out)
+			if len(statuses) == 0 || statuses[0] != tt.status {
+				t.Fatalf("statuses = %v, want %d first:\n%s", statuses, tt.status, out)
+			}
+			hasLocation := strings.Contains(out, "Location: "+tt.location+"\r\n")
+			if tt.location != "" && !hasLocation || tt.location == "" && strings.Contains(out, "Location:") {
+				t.Fatalf("want Location %q:\n%s", tt.location, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+	"web-server/internal/certs"
+	"web-server/internal/config"
+	"web-server/internal/httpx"
+	"web-server/internal/model"
+	"web-server/pkg/logger"
+)
+
+// Logging пишет в лог строку доступа для каждого запроса
+func Logging(next httpx.HandlerFunc) httpx.HandlerFunc {
+	return func(w *httpx.ResponseWriter, r *httpx.Request) {
+		start := time.Now()
+		logger.Log.Info("получен запрос", "method", r.Method, "path", r.URL.Path, "query", r.URL.RawQuery)
+
+		next(w, r)
+
+		logger.Log.Info("запрос обработан",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", w.Status(),
+			"bytes", w.Written(),
+			"duration", time.Since(start),
+			"address", r.RemoteAddr,
+		)
+	}
+}
+
+// Recover перехватывает панику в обработчике и отвечает 500 с JSON-ошибкой,
+// чтобы упавший запрос не ронял весь сервер
+func Recover(next httpx.HandlerFunc) httpx.HandlerFunc {
+	return func(w *httpx.ResponseWriter, r *httpx.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				httpx.RecoverPanic(w, r, rec)
+			}
+		}()
+		next(w, r)
+	}
+}
+
+// Timing добавляет заголовок X-Response-Time со временем работы обработчика.
+// Для потоковых ответов заголовки уже отправлены, и время только логируется
+func Timing(next httpx.HandlerFunc) httpx.HandlerFunc {
+	return func(w *httpx.ResponseWriter, r *httpx.Request) {
+		start := time.Now()
+		next(w, r)
+		elapsed := time.Since(start)
+
+		if !w.HeaderSent() {
+			w.Header().Set("X-Response-Time", fmt.Sprintf("%.3fms", float64(elapsed.Microseconds())/1000))
+		}
+		logger.Log.Debug("время обработки запроса", "path", r.URL.Path, "duration", elapsed)
+	}
+}
+
+// Compress сжимает ответы gzip или deflate, если клиент их принимает.
+// Мелкие тела и типы не из CompressTypes уходят как есть.
+// Written() в Logging по-прежнему показывает размер до сжатия
+func Compress(cfg *config.Config) func(next httpx.HandlerFunc) httpx.HandlerFunc {
+	return func(next httpx.HandlerFunc) httpx.HandlerFunc {
+		return func(w *httpx.ResponseWriter, r *httpx.Request) {
+			encoding := httpx.NegotiateEncoding(r.Headers.Get("Accept-Encoding"))
+			w.EnableCompression(encoding, cfg.CompressMinBytes, cfg.CompressTypes)
+			next(w, r)
+		}
+	}
+}
+
+// PeerIdentity опознаёт клиента по проверенному сертификату (mTLS) и кладёт
+// результат в r.Peer. users загружает пользователя по id из
+// TLS_CLIENT_IDENTITIES. Сертификат без правила оставляет r.Peer пустым —
+// такой клиент должен авторизоваться иначе, например JWT
+func PeerIdentity(cfg *config.Config, users func(id int) (model.User, bool, error)) func(next httpx.HandlerFunc) httpx.HandlerFunc {
+	return func(next httpx.HandlerFunc) httpx.HandlerFunc {
+		return func(w *httpx.ResponseWriter, r *httpx.Request) {
+			IdentifyPeer(cfg, users, r)
+			next(w, r)
+		}
+	}
+}
+
+// IdentifyPeer заполняет r.Peer, если ещё не заполнен, и возвращает его.
+// Guard'ы маршрутов выполняются до middleware роутера и зовут его сами
+func IdentifyPeer(cfg *config.Config, users func(id int) (model.User, bool, error), r *httpx.Request) *model.Identity {
+	if r.Peer == nil && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		r.Peer = identify(cfg, users, r)
+	}
+	return r.Peer
+}
+
+func identify(cfg *config.Config, users func(id int) (model.User, bool, error), r *httpx.Request) *model.Identity {
+	// VerifiedChains[0][0] — лист проверенной цепочки, он же PeerCertificates[0]
+	cert := r.TLS.VerifiedChains[0][0]
+	rule, ok := certs.Identify(cert, cfg.TLSClientIdentities)
+	if !ok {
+		logger.Log.Debug("сертификат клиента не сопоставлен", "subject", cert.Subject.String(), "address", r.RemoteAddr)
+		return nil
+	}
+
+	peer := &model.Identity{Subject: rule.Match, Service: rule.Service}
+	if rule.UserID != 0 {
+		user, found, err := users(rule.UserID)
+		if err != nil {
+			logger.Log.Error("ошибка загрузки пользователя по сертификату", "id", rule.UserID, "error", err)
+			return nil
+		}
+		if !found {
+			logger.Log.Warn("сертификат сопоставлен несуществующему пользователю", "subject", rule.Match, "id", rule.UserID)
+			return nil
+		}
+		peer.User = &user
+	}
+	return peer
+}
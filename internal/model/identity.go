@@ -0,0 +1,9 @@
+package model
+
+// Identity — кто обращается к API по клиентскому сертификату (mTLS):
+// пользователь из базы или внутренний сервис
+type Identity struct {
+	Subject string // поле сертификата, по которому опознан клиент: "cn:alice", "dns:billing.internal"
+	User    *User  // nil — клиент не пользователь
+	Service string // имя сервиса, "" — клиент не сервис
+}
@@ -1,9 +1,14 @@
 package model
 
+import "time"
+
 type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
-	Login    string `json:"login"`
-	Password string `json:"-"`
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Login     string    `json:"login"`
+	Password  string    `json:"-"`
+	Version   int       `json:"version"` // растёт при каждом изменении, из него строится ETag
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
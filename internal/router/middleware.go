@@ -0,0 +1,48 @@
+package router
+
+import (
+	"strings"
+	"web-server/internal/httpx"
+)
+
+// Middleware оборачивает обработчик дополнительной логикой
+type Middleware func(next httpx.HandlerFunc) httpx.HandlerFunc
+
+// Chain оборачивает h в mw так, что первый middleware выполняется первым
+func Chain(h httpx.HandlerFunc, mw ...Middleware) httpx.HandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// Group — набор маршрутов с общим префиксом пути и общими middleware
+type Group struct {
+	router     *Router
+	prefix     string
+	middleware []Middleware
+}
+
+// Group создаёт группу маршрутов с префиксом prefix
+func (rt *Router) Group(prefix string, mw ...Middleware) *Group {
+	return &Group{router: rt, prefix: strings.TrimRight(prefix, "/"), middleware: mw}
+}
+
+// Group создаёт вложенную группу, наследующую префикс и middleware
+func (g *Group) Group(prefix string, mw ...Middleware) *Group {
+	all := append(append([]Middleware{}, g.middleware...), mw...)
+	return &Group{router: g.router, prefix: g.prefix + strings.TrimRight(prefix, "/"), middleware: all}
+}
+
+// Use добавляет middleware группы. Действует на маршруты,
+// зарегистрированные после вызова
+func (g *Group) Use(mw ...Middleware) {
+	g.middleware = append(g.middleware, mw...)
+}
+
+// Handle регистрирует маршрут относительно префикса группы
+func (g *Group) Handle(pattern string, h httpx.HandlerFunc, mw ...Middleware) *Route {
+	method, path, _ := strings.Cut(pattern, " ")
+	all := append(append([]Middleware{}, g.middleware...), mw...)
+	return g.router.Handle(method+" "+g.prefix+path, h, all...)
+}
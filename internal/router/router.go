@@ -0,0 +1,325 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"web-server/internal/httpx"
+	"web-server/pkg/logger"
+)
+
+// Router сопоставляет запросы с маршрутами вида "GET /users/{id:int}".
+// Неизвестный путь — 404, известный путь с другим методом — 405 с Allow.
+// HEAD обслуживается GET-обработчиком, OPTIONS отвечает списком методов
+type Router struct {
+	routes     []*Route
+	middleware []Middleware
+}
+
+// Route — зарегистрированный маршрут. Методы Route позволяют
+// донастроить его после Handle
+type Route struct {
+	method   string
+	segments []segment
+	handler  httpx.HandlerFunc
+	maxBody  int64
+	guards   []Guard
+}
+
+// Guard проверяет запрос по заголовкам до чтения тела, например авторизацию.
+// Если запрос не проходит, Guard пишет ответ в w и возвращает false
+type Guard func(w *httpx.ResponseWriter, r *httpx.Request) bool
+
+// Guard добавляет маршруту проверки, которые выполняются до чтения тела.
+// Клиент с Expect: 100-continue получит отказ, не отправляя тело
+func (rte *Route) Guard(g ...Guard) *Route {
+	rte.guards = append(rte.guards, g...)
+	return rte
+}
+
+// MaxBody задаёт предел тела запроса для маршрута вместо MAX_BODY_BYTES,
+// например больший для загрузки файлов
+func (rte *Route) MaxBody(n int64) *Route {
+	rte.maxBody = n
+	return rte
+}
+
+// segment — часть пути между слешами: литерал или параметр
+type segment struct {
+	literal string
+	param   string // имя параметра, если сегмент вида {name}
+	kind    string // тип параметра: "" (любая строка), "int" или "..." — остаток пути
+}
+
+func New() *Router {
+	return &Router{}
+}
+
+// Use добавляет middleware, которые выполняются для каждого запроса,
+// в том числе для ответов 404/405
+func (rt *Router) Use(mw ...Middleware) {
+	rt.middleware = append(rt.middleware, mw...)
+}
+
+// Handle регистрирует обработчик. pattern — "METHOD /path/{param[:type]}",
+// последним сегментом может быть {name...} — остаток пути, в том числе пустой.
+// mw оборачивают только этот маршрут
+func (rt *Router) Handle(pattern string, h httpx.HandlerFunc, mw ...Middleware) *Route {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok || method == "" || !strings.HasPrefix(path, "/") {
+		panic(fmt.Sprintf("router: invalid pattern %q", pattern))
+	}
+
+	segments, err := parsePattern(path)
+	if err != nil {
+		panic(fmt.Sprintf("router: %v", err))
+	}
+	rte := &Route{
+		method:   strings.ToUpper(method),
+		segments: segments,
+		handler:  Chain(h, mw...),
+	}
+	rt.routes = append(rt.routes, rte)
+	return rte
+}
+
+// Prepare вызывается до чтения тела: применяет предел тела маршрута
+// и выполняет его Guard'ы. Клиенту, ждущему 100 Continue, сразу
+// отвечает 404/405, если маршрута нет
+func (rt *Router) Prepare(w *httpx.ResponseWriter, r *httpx.Request) bool {
+	rte, params, _ := rt.lookup(r)
+	if rte == nil {
+		if r.ExpectsContinue() {
+			rt.Serve(w, r)
+			return false
+		}
+		return true
+	}
+
+	if rte.maxBody > 0 {
+		r.MaxBodyBytes = rte.maxBody
+	}
+	if len(rte.guards) == 0 {
+		return true
+	}
+
+	// middleware роутера здесь не вызываются: они отработают в Serve,
+	// а дважды — это две записи в логе на один запрос
+	r.Params = params
+	if !runGuards(rte.guards, w, r) {
+		logger.Log.Info("запрос отклонён до чтения тела",
+			"method", r.Method, "path", r.URL.Path, "status", w.Status(), "address", r.RemoteAddr)
+		return false
+	}
+	return true
+}
+
+// runGuards выполняет проверки по порядку. Пропускает запрос, только если
+// все вернули true: паника в проверке — это 500 и отказ, а не доступ
+func runGuards(guards []Guard, w *httpx.ResponseWriter, r *httpx.Request) (ok bool) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			httpx.RecoverPanic(w, r, rec)
+			ok = false
+		}
+	}()
+	for _, g := range guards {
+		if !g(w, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Serve пропускает запрос через middleware роутера и вызывает обработчик маршрута
+func (rt *Router) Serve(w *httpx.ResponseWriter, r *httpx.Request) {
+	Chain(rt.dispatch, rt.middleware...)(w, r)
+}
+
+// dispatch находит маршрут для запроса и вызывает его обработчик
+func (rt *Router) dispatch(w *httpx.ResponseWriter, r *httpx.Request) {
+	rte, params, allowed := rt.lookup(r)
+	if rte != nil {
+		// для HEAD это GET-маршрут — ResponseWriter сам не отправит тело
+		r.Params = params
+		rte.handler(w, r)
+		return
+	}
+
+	if len(allowed) == 0 {
+		w.WriteHeader(404)
+		return
+	}
+
+	w.Header().Set("Allow", allowHeader(allowed))
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(204)
+		return
+	}
+	w.WriteHeader(405)
+}
+
+// lookup ищет маршрут для метода и пути запроса. Из нескольких подходящих
+// выбирается самый точный: литерал важнее {id:int}, тот — {name}, а {rest...}
+// последний, независимо от порядка регистрации. HEAD без своего маршрута
+// получает GET-маршрут. Если маршрута нет, allowed содержит методы,
+// зарегистрированные для этого пути
+func (rt *Router) lookup(r *httpx.Request) (*Route, map[string]string, []string) {
+	var allowed []string
+	var best, getRoute *Route
+	var bestParams, getParams map[string]string
+
+	for _, rte := range rt.routes {
+		params, ok := rte.match(r.URL.Path)
+		if !ok {
+			continue
+		}
+		switch {
+		case rte.method == r.Method:
+			if best == nil || rte.moreSpecific(best) {
+				best, bestParams = rte, params
+			}
+		case rte.method == "GET":
+			if getRoute == nil || rte.moreSpecific(getRoute) {
+				getRoute, getParams = rte, params
+			}
+		}
+		allowed = append(allowed, rte.method)
+	}
+
+	if best != nil {
+		return best, bestParams, nil
+	}
+	if r.Method == "HEAD" && getRoute != nil {
+		return getRoute, getParams, nil
+	}
+	return nil, nil, allowed
+}
+
+// moreSpecific сравнивает шаблоны по сегментам слева направо:
+// решает первый сегмент, где точность различается
+func (rte *Route) moreSpecific(other *Route) bool {
+	for i := 0; i < max(len(rte.segments), len(other.segments)); i++ {
+		if a, b := rte.rankAt(i), other.rankAt(i); a != b {
+			return a > b
+		}
+	}
+	return false
+}
+
+// rankAt — точность i-го сегмента. Сегмента нет — значит, другой шаблон
+// в этом месте может быть только {rest...} с пустым остатком, и точнее
+// тот, где остатка нет вовсе
+func (rte *Route) rankAt(i int) int {
+	if i >= len(rte.segments) {
+		return 4
+	}
+	return rte.segments[i].rank()
+}
+
+// rank — точность сегмента: литерал, {id:int}, {name}, {rest...}
+func (seg segment) rank() int {
+	switch {
+	case seg.param == "":
+		return 3
+	case seg.kind == "int":
+		return 2
+	case seg.kind == "":
+		return 1
+	}
+	return 0
+}
+
+// allowHeader собирает значение Allow с учётом автоматических HEAD и OPTIONS
+func allowHeader(methods []string) string {
+	set := map[string]bool{"OPTIONS": true}
+	for _, m := range methods {
+		set[m] = true
+		if m == "GET" {
+			set["HEAD"] = true
+		}
+	}
+	list := make([]string, 0, len(set))
+	for m := range set {
+		list = append(list, m)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
+
+func parsePattern(path string) ([]segment, error) {
+	var segments []segment
+	parts := splitPath(path)
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "{") {
+			segments = append(segments, segment{literal: part})
+			continue
+		}
+		if !strings.HasSuffix(part, "}") {
+			return nil, fmt.Errorf("invalid segment %q in %q", part, path)
+		}
+		name, kind, _ := strings.Cut(part[1:len(part)-1], ":")
+		if rest, ok := strings.CutSuffix(name, "..."); ok && kind == "" {
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("%q must be the last segment in %q", part, path)
+			}
+			name, kind = rest, "..."
+		}
+		if name == "" {
+			return nil, fmt.Errorf("empty parameter name in %q", path)
+		}
+		if kind != "" && kind != "int" && kind != "..." {
+			return nil, fmt.Errorf("unknown parameter type %q in %q", kind, path)
+		}
+		segments = append(segments, segment{param: name, kind: kind})
+	}
+	return segments, nil
+}
+
+// match сравнивает путь с шаблоном и возвращает параметры
+func (rte *Route) match(path string) (map[string]string, bool) {
+	parts := splitPath(path)
+	rest := len(rte.segments) > 0 && rte.segments[len(rte.segments)-1].kind == "..."
+	if len(parts) != len(rte.segments) && !(rest && len(parts) >= len(rte.segments)-1) {
+		return nil, false
+	}
+
+	var params map[string]string
+	for i, seg := range rte.segments {
+		if seg.kind == "..." {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[seg.param] = strings.Join(parts[i:], "/")
+			break
+		}
+		if seg.param == "" {
+			if parts[i] != seg.literal {
+				return nil, false
+			}
+			continue
+		}
+		if parts[i] == "" {
+			return nil, false
+		}
+		if seg.kind == "int" {
+			if _, err := strconv.Atoi(parts[i]); err != nil {
+				return nil, false
+			}
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[seg.param] = parts[i]
+	}
+	return params, true
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
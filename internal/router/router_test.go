@@ -0,0 +1,207 @@
+package router_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"web-server/internal/httpx"
+	"web-server/internal/router"
+	"web-server/internal/servertest"
+)
+
+// do отправляет один запрос без тела с Connection: close и разбирает ответ net/http
+func do(t *testing.T, rt *router.Router, method, path string) (*http.Response, string) {
+	t.Helper()
+	raw := method + " " + path + " HTTP/1.1\r\nHost: x\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"
+	out := servertest.Exchange(t, servertest.Config(), rt, raw)
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(out)), &http.Request{Method: method})
+	if err != nil {
+		t.Fatalf("%s %s: %v\n%s", method, path, err, out)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+// label отвечает текстом, по которому видно, какой маршрут сработал
+func label(format string, params ...string) httpx.HandlerFunc {
+	return func(w *httpx.ResponseWriter, r *httpx.Request) {
+		args := make([]any, len(params))
+		for i, p := range params {
+			args[i] = r.Params[p]
+		}
+		fmt.Fprintf(w, format, args...)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	rt := router.New()
+	// литералы регистрируются последними: выбор не зависит от порядка
+	rt.Handle("GET /users/{id}", label("name %s", "id"))
+	rt.Handle("GET /users/{id:int}", label("int %s", "id"))
+	rt.Handle("GET /users", label("list"))
+	rt.Handle("POST /users", label("create"))
+	rt.Handle("DELETE /users/{id:int}", label("delete %s", "id"))
+	rt.Handle("GET /users/me", label("me"))
+	rt.Handle("GET /files/{path...}", label("file %q", "path"))
+	rt.Handle("GET /files", label("files"))
+	rt.Handle("GET /teams/{team}/users/{id:int}", label("%s/%s", "team", "id"))
+
+	tests := []struct {
+		method, path string
+		status       int
+		body         string
+		allow        string
+	}{
+		{"GET", "/users", 200, "list", ""},
+		{"POST", "/users", 200, "create", ""},
+		{"GET", "/users/42", 200, "int 42", ""},
+		{"GET", "/users/bob", 200, "name bob", ""},
+		{"GET", "/users/4x2", 200, "name 4x2", ""},
+		{"GET", "/users/me", 200, "me", ""},
+		{"DELETE", "/users/7", 200, "delete 7", ""},
+		{"GET", "/files/a/b/c.txt", 200, `file "a/b/c.txt"`, ""},
+		{"GET", "/files/", 200, "files", ""},
+		{"GET", "/files", 200, "files", ""},
+		{"GET", "/teams/core/users/3", 200, "core/3", ""},
+		{"GET", "/teams/core/users/x", 404, "", ""},
+		{"GET", "/users/42/extra", 404, "", ""},
+		{"GET", "/nope", 404, "", ""},
+		// HEAD — GET-маршрут без тела
+		{"HEAD", "/users/42", 200, "", ""},
+		{"HEAD", "/files/x", 200, "", ""},
+		// 405 с точным набором методов для этого пути
+		{"PUT", "/users", 405, "", "GET, HEAD, OPTIONS, POST"},
+		{"PUT", "/users/42", 405, "", "DELETE, GET, HEAD, OPTIONS"},
+		{"DELETE", "/users/bob", 405, "", "GET, HEAD, OPTIONS"},
+		{"POST", "/files/x", 405, "", "GET, HEAD, OPTIONS"},
+		// OPTIONS отвечает сам
+		{"OPTIONS", "/users", 204, "", "GET, HEAD, OPTIONS, POST"},
+		{"OPTIONS", "/users/42", 204, "", "DELETE, GET, HEAD, OPTIONS"},
+		{"OPTIONS", "/nope", 404, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			resp, body := do(t, rt, tt.method, tt.path)
+			if resp.StatusCode != tt.status || body != tt.body {
+				t.Fatalf("got %d %q, want %d %q", resp.StatusCode, body, tt.status, tt.body)
+			}
+			if got := resp.Header.Get("Allow"); got != tt.allow {
+				t.Fatalf("Allow = %q, want %q", got, tt.allow)
+			}
+		})
+	}
+}
+
+// свой HEAD-маршрут важнее GET
+func TestExplicitHead(t *testing.T) {
+	rt := router.New()
+	rt.Handle("GET /a", label("get"))
+	rt.Handle("HEAD /a", func(w *httpx.ResponseWriter, r *httpx.Request) {
+		w.Header().Set("X-Route", "head")
+	})
+	resp, _ := do(t, rt, "HEAD", "/a")
+	if resp.StatusCode != 200 || resp.Header.Get("X-Route") != "head" {
+		t.Fatalf("HEAD /a = %d %v", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestGuardsSkipRouterMiddleware(t *testing.T) {
+	calls := 0
+	rt := router.New()
+	rt.Use(func(next httpx.HandlerFunc) httpx.HandlerFunc {
+		return func(w *httpx.ResponseWriter, r *httpx.Request) {
+			calls++
+			next(w, r)
+		}
+	})
+	allow := true
+	rt.Handle("POST /items", func(w *httpx.ResponseWriter, r *httpx.Request) {
+		w.WriteHeader(201)
+	}).Guard(func(w *httpx.ResponseWriter, r *httpx.Request) bool {
+		if !allow {
+			w.WriteHeader(401)
+		}
+		return allow
+	})
+
+	out := servertest.Exchange(t, servertest.Config(), rt, "POST /items HTTP/1.1\r\nHost: x\r\nContent-Length: 2\r\nConnection: close\r\n\r\nok")
+	if !strings.HasPrefix(out, "HTTP/1.1 201 ") || calls != 1 {
+		t.Fatalf("allowed: calls = %d, response %q", calls, out)
+	}
+
+	// отказ проверки отвечает сам, middleware и обработчик не вызываются
+	allow, calls = false, 0
+	out = servertest.Exchange(t, servertest.Config(), rt, "POST /items HTTP/1.1\r\nHost: x\r\nContent-Length: 2\r\n\r\nok")
+	if !strings.HasPrefix(out, "HTTP/1.1 401 ") || calls != 0 {
+		t.Fatalf("rejected: calls = %d, response %q", calls, out)
+	}
+}
+
+func TestGuardPanicFailsClosed(t *testing.T) {
+	served := false
+	rt := router.New()
+	rt.Handle("DELETE /items/{id:int}", func(w *httpx.ResponseWriter, r *httpx.Request) {
+		served = true
+		w.WriteHeader(204)
+	}).Guard(func(w *httpx.ResponseWriter, r *httpx.Request) bool {
+		panic("guard failed")
+	})
+
+	out := servertest.Exchange(t, servertest.Config(), rt, "DELETE /items/1 HTTP/1.1\r\nHost: x\r\n\r\n")
+	if !strings.HasPrefix(out, "HTTP/1.1 500 ") || served {
+		t.Fatalf("served = %v, response %q", served, out)
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var log []string
+	record := func(name string) router.Middleware {
+		return func(next httpx.HandlerFunc) httpx.HandlerFunc {
+			return func(w *httpx.ResponseWriter, r *httpx.Request) {
+				log = append(log, name)
+				next(w, r)
+				log = append(log, "/"+name)
+			}
+		}
+	}
+	allow := true
+	guard := func(w *httpx.ResponseWriter, r *httpx.Request) bool {
+		// проверка идёт до чтения тела
+		log = append(log, fmt.Sprintf("guard body=%d", len(r.Body)))
+		if !allow {
+			w.WriteHeader(401)
+		}
+		return allow
+	}
+
+	rt := router.New()
+	rt.Use(record("global"))
+	api := rt.Group("/api", record("group"))
+	v1 := api.Group("/v1", record("subgroup"))
+	v1.Use(record("group-use"))
+	v1.Handle("POST /items", func(w *httpx.ResponseWriter, r *httpx.Request) {
+		log = append(log, fmt.Sprintf("handler body=%d", len(r.Body)))
+	}, record("route")).Guard(guard)
+
+	raw := "POST /api/v1/items HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\nConnection: close\r\n\r\nhello"
+	servertest.Exchange(t, servertest.Config(), rt, raw)
+	want := "[guard body=0 global group subgroup group-use route handler body=5 /route /group-use /subgroup /group /global]"
+	if got := fmt.Sprint(log); got != want {
+		t.Fatalf("order = %s\nwant    %s", got, want)
+	}
+
+	// отказ останавливает всё до middleware, обработчика и чтения тела
+	allow, log = false, nil
+	out := servertest.Exchange(t, servertest.Config(), rt,
+		"POST /api/v1/items HTTP/1.1\r\nHost: x\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n")
+	if !strings.HasPrefix(out, "HTTP/1.1 401 ") || strings.Contains(out, "100 Continue") {
+		t.Fatalf("rejected response = %q", out)
+	}
+	if got := fmt.Sprint(log); got != "[guard body=0]" {
+		t.Fatalf("rejected order = %s", got)
+	}
+}
@@ -1,34 +1,190 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
+	"sync"
+	"web-server/internal/certs"
 	"web-server/internal/config"
 	"web-server/internal/handler"
+	"web-server/internal/httpx"
 	"web-server/internal/storage"
 	"web-server/pkg/logger"
 )
 
-func Start(cfg *config.Config, storage *storage.Storage) {
-	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+// Server принимает TCP соединения и умеет корректно останавливаться:
+// перестаёт принимать новые соединения, дожидается текущих запросов,
+// а по истечении дедлайна закрывает оставшиеся соединения принудительно
+type Server struct {
+	cfg     *config.Config
+	storage *storage.Storage
+	handler httpx.Handler
+
+	// tlsConfig задан, если в конфиге есть сертификаты
+	tlsConfig *tls.Config
+	certs     *certs.Store
+
+	// ctx отменяется при Shutdown — сигнал соединениям не ждать новых запросов
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closing  bool
+	wg       sync.WaitGroup
+}
+
+func New(cfg *config.Config, storage *storage.Storage) (*Server, error) {
+	h, err := handler.NewRouter(storage, cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		cfg:     cfg,
+		storage: storage,
+		handler: h,
+		ctx:     ctx,
+		cancel:  cancel,
+		conns:   make(map[net.Conn]struct{}),
+	}
+	if len(cfg.TLSCerts) > 0 {
+		if s.certs, err = certs.Load(cfg.TLSCerts); err != nil {
+			cancel()
+			return nil, err
+		}
+		if s.tlsConfig, err = certs.TLSConfig(cfg, s.certs); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Start слушает адрес из конфига и обслуживает соединения.
+// Блокируется до ошибки listener или до вызова Shutdown (тогда возвращает nil)
+func (s *Server) Start() error {
+	addr := fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.Port)
 
 	listener, err := net.Listen("tcp4", addr)
 	if err != nil {
 		logger.Log.Error("ошибка запуска listener", "error", err)
-		return
+		return err
 	}
-	defer listener.Close()
+	return s.Serve(listener)
+}
 
-	logger.Log.Info("listener запущен на", "address", addr)
+// Serve обслуживает соединения уже открытого listener и закрывает его
+// при остановке. Нужен, когда адрес выбирает вызывающий — например,
+// тестам с портом 0. С сертификатами в конфиге соединения идут через TLS
+func (s *Server) Serve(listener net.Listener) error {
+	if s.tlsConfig != nil {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		listener.Close()
+		return nil
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	if s.certs != nil && s.cfg.TLSReloadInterval > 0 {
+		go s.certs.Watch(s.ctx, s.cfg.TLSReloadInterval)
+	}
+	logger.Log.Info("listener запущен на", "address", listener.Addr(), "tls", s.tlsConfig != nil)
 
 	for {
 		conn, err := listener.Accept() //ожидание вход соединения
 		if err != nil {
+			if errors.Is(err, net.ErrClosed) && s.isClosing() {
+				return nil
+			}
 			logger.Log.Error("ошибка принятия соединения", "error", err)
-			return
+			return err
 		}
-		logger.Log.Info("новое подключение", "address", conn.RemoteAddr())
-		go handler.HandleConnection(conn, storage, cfg)
+
+		if !s.trackConn(conn) {
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer s.untrackConn(conn)
+			httpx.ServeConn(s.ctx, conn, s.handler, s.cfg)
+		}()
+	}
+}
+
+// Shutdown останавливает сервер. Ждёт завершения активных соединений,
+// пока не истечёт ctx, после чего закрывает оставшиеся.
+// В конце закрывает хранилище и сбрасывает лог на диск
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	active := len(s.conns)
+	s.mu.Unlock()
+
+	logger.Log.Info("остановка сервера", "active_connections", active, "panics", httpx.Panics())
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+		logger.Log.Info("все соединения завершены")
+	case <-ctx.Done():
+		err = ctx.Err()
+		s.mu.Lock()
+		logger.Log.Warn("дедлайн остановки истёк, закрываем соединения", "remaining", len(s.conns))
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		<-done
+	}
+
+	if closeErr := s.storage.Close(); closeErr != nil {
+		logger.Log.Error("ошибка закрытия хранилища", "error", closeErr)
+		err = errors.Join(err, closeErr)
 	}
+	logger.Sync()
+	return err
+}
+
+func (s *Server) isClosing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closing
+}
+
+// trackConn запоминает соединение. Возвращает false, если сервер уже останавливается
+func (s *Server) trackConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
 
+func (s *Server) untrackConn(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	s.wg.Done()
 }
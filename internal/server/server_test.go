@@ -0,0 +1,204 @@
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"web-server/internal/config"
+	"web-server/internal/model"
+	"web-server/internal/servertest"
+)
+
+// startUpdate начинает PUT пользователя и отправляет только часть тела.
+// Ответ 100 Continue значит, что сервер уже читает тело — запрос в работе.
+// Остаток тела возвращается вызывающему
+func startUpdate(t *testing.T, s *servertest.Server) (net.Conn, *bufio.Reader, string) {
+	t.Helper()
+	var user model.User
+	s.JSON(t, "POST", "/api/v1/users", model.User{Username: "Before", Role: "user"}).AssertStatus(t, 201).Decode(t, &user)
+
+	body := `{"username":"After","role":"user"}`
+	conn := s.Dial(t)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintf(conn, "PUT /api/v1/users/%d HTTP/1.1\r\nHost: x\r\nContent-Type: application/json\r\n"+
+		"Content-Length: %d\r\nExpect: 100-continue\r\n\r\n%s", user.ID, len(body), body[:10])
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil || resp.StatusCode != 100 {
+		t.Fatalf("interim response = %v, %v; want 100 Continue", resp, err)
+	}
+	return conn, reader, body[10:]
+}
+
+// waitListenerClosed ждёт, пока сервер перестанет принимать соединения
+func waitListenerClosed(t *testing.T, addr string) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		conn, err := net.Dial("tcp4", addr)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}
+	t.Fatal("listener still accepts connections")
+}
+
+func TestShutdownWaitsForRequest(t *testing.T) {
+	s := servertest.New(t)
+	conn, reader, rest := startUpdate(t, s)
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+	waitListenerClosed(t, s.Addr)
+
+	// остановка уже идёт, а начатый запрос дочитывается и получает ответ
+	io.WriteString(conn, rest)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || !strings.Contains(string(data), `"username":"After"`) {
+		t.Fatalf("response = %d %s", resp.StatusCode, data)
+	}
+
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Fatalf("Shutdown = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Shutdown did not return after the request finished")
+	}
+	if _, err := reader.ReadByte(); err == nil {
+		t.Fatal("connection still open after Shutdown")
+	}
+}
+
+func TestShutdownDeadlineClosesBusyConn(t *testing.T) {
+	s := servertest.New(t)
+	conn, reader, _ := startUpdate(t, s)
+
+	// остаток тела не придёт: соединение занято до дедлайна остановки
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Shutdown took %v", elapsed)
+	}
+
+	// сервер закрыл соединение сам, не дожидаясь ReadBodyTimeout
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err := io.ReadAll(reader)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		t.Fatal("busy connection was not closed")
+	}
+	if _, err := net.Dial("tcp4", s.Addr); err == nil {
+		t.Fatal("listener still accepts connections")
+	}
+}
+
+func TestReadHeaderTimeout(t *testing.T) {
+	s := servertest.New(t, func(cfg *config.Config) { cfg.ReadHeaderTimeout = 100 * time.Millisecond })
+	conn := s.Dial(t)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// заголовки обрываются на середине и не дописываются
+	start := time.Now()
+	io.WriteString(conn, "GET /api/v1/users HTTP/1.1\r\nHost: x\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 408 || !resp.Close {
+		t.Fatalf("response = %d close=%v, want 408 with Connection: close", resp.StatusCode, resp.Close)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("408 after %v, before ReadHeaderTimeout", elapsed)
+	}
+	io.Copy(io.Discard, resp.Body)
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Fatalf("after 408 read = %v, want EOF", err)
+	}
+}
+
+func TestKeepAliveIdleTimeout(t *testing.T) {
+	s := servertest.New(t, func(cfg *config.Config) { cfg.KeepAliveTimeout = 100 * time.Millisecond })
+	conn := s.Dial(t)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	// два запроса подряд укладываются в KeepAliveTimeout — соединение живёт
+	for range 2 {
+		io.WriteString(conn, "GET /api/v1/users HTTP/1.1\r\nHost: x\r\n\r\n")
+		resp, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode != 200 || resp.Close {
+			t.Fatalf("response = %d close=%v, want 200 keep-alive", resp.StatusCode, resp.Close)
+		}
+	}
+
+	// молчащий клиент: сервер закрывает соединение без ответа
+	start := time.Now()
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Fatalf("idle read = %v, want EOF", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("idle connection closed after %v, want about KeepAliveTimeout", elapsed)
+	}
+}
+
+// досрочный отказ с непрочитанным телом: ответ должен дойти до клиента,
+// который ещё отправляет тело, а не потеряться в RST от закрытия
+func TestEarlyResponseWhileSendingBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"guard", "", 401},
+		{"expect", "Expect: unknown\r\n", 417},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := servertest.New(t, func(cfg *config.Config) { cfg.AuthRequired = true })
+			conn := s.Dial(t)
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			body := strings.Repeat("x", 64<<10)
+			fmt.Fprintf(conn, "POST /api/v1/users HTTP/1.1\r\nHost: x\r\nContent-Type: application/json\r\n%s"+
+				"Content-Length: %d\r\n\r\n%s", tt.header, len(body), body[:16<<10])
+			// клиент занят отправкой и читает ответ не сразу
+			time.Sleep(50 * time.Millisecond)
+
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if err != nil {
+				t.Fatalf("response lost: %v", err)
+			}
+			if resp.StatusCode != tt.status || !resp.Close {
+				t.Fatalf("response = %d close=%v, want %d with Connection: close", resp.StatusCode, resp.Close, tt.status)
+			}
+			// остаток тела сервер дочитывает, запись не обрывается
+			if _, err := io.WriteString(conn, body[16<<10:]); err != nil {
+				t.Fatalf("write rest of body: %v", err)
+			}
+		})
+	}
+}
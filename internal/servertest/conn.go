@@ -0,0 +1,38 @@
+package servertest
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"web-server/internal/config"
+	"web-server/internal/httpx"
+)
+
+// Exchange прогоняет сырые байты через httpx.ServeConn с handler без
+// сервера и сети — через net.Pipe. Возвращает всё, что сервер ответил до
+// закрытия соединения, поэтому последний запрос в raw должен его закрыть
+// (Connection: close) или cfg — держать короткий KeepAliveTimeout
+func Exchange(t testing.TB, cfg *config.Config, handler httpx.Handler, raw string) string {
+	t.Helper()
+	client, server := net.Pipe()
+	defer client.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		httpx.ServeConn(context.Background(), server, handler, cfg)
+	}()
+	// сервер может перестать читать посреди запроса — запись не должна блокировать тест
+	go io.WriteString(client, raw)
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	out, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatalf("servertest: read response: %v", err)
+	}
+	<-done
+	return string(out)
+}
@@ -0,0 +1,315 @@
+// Package servertest запускает сервер внутри теста — аналог httptest
+// для нашего HTTP-стека. Порт выбирает система, база SQLite живёт в памяти,
+// всё останавливается в t.Cleanup
+package servertest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"web-server/internal/certs"
+	"web-server/internal/config"
+	"web-server/internal/httpx"
+	"web-server/internal/server"
+	"web-server/internal/storage"
+	"web-server/pkg/jwt"
+)
+
+// Server — запущенный сервер с собственной базой
+type Server struct {
+	URL   string // http://127.0.0.1:port или https:// с TLS
+	Addr  string // 127.0.0.1:port — для сырых соединений
+	Cfg   *config.Config
+	Store *storage.Storage
+
+	srv       *server.Server
+	client    *http.Client
+	done      chan error
+	tlsConfig *tls.Config // nil — сервер без TLS
+}
+
+// Config возвращает значения по умолчанию из LoadCfg без чтения окружения
+func Config() *config.Config {
+	return &config.Config{
+		Host:                   "127.0.0.1",
+		LogLevel:               "debug",
+		ApiBasePath:            "/api/v1",
+		JwtSecret:              "servertest-secret",
+		JwtExpires:             60,
+		KeepAliveTimeout:       5 * time.Second,
+		KeepAliveMaxRequests:   100,
+		ReadHeaderTimeout:      10 * time.Second,
+		ReadBodyTimeout:        30 * time.Second,
+		WriteTimeout:           30 * time.Second,
+		MaxRequestLineBytes:    8 << 10,
+		MaxHeaderBytes:         64 << 10,
+		MaxHeaderCount:         100,
+		MaxBodyBytes:           10 << 20,
+		MultipartMemoryBytes:   1 << 20,
+		MultipartMaxFileBytes:  10 << 20,
+		MultipartMaxTotalBytes: 32 << 20,
+		MultipartMaxParts:      100,
+		CompressEnabled:        true,
+		CompressMinBytes:       1024,
+		CompressTypes:          []string{"application/json", "application/javascript", "image/svg+xml", "text/"},
+		WSMaxMessageBytes:      64 << 10,
+		WSPingInterval:         30 * time.Second,
+		TLSMinVersion:          tls.VersionTLS12,
+		TLSClientAuth:          "off",
+		StrictParsing:          true,
+		ShutdownTimeout:        5 * time.Second,
+	}
+}
+
+// dbSeq делает имена баз в памяти уникальными в пределах процесса
+var dbSeq atomic.Int64
+
+// New запускает сервер на свободном порту. configure может поменять
+// конфиг до старта, например включить AuthRequired
+func New(t testing.TB, configure ...func(*config.Config)) *Server {
+	t.Helper()
+	cfg := Config()
+	for _, f := range configure {
+		f(cfg)
+	}
+	if cfg.UploadTempDir == "" {
+		cfg.UploadTempDir = t.TempDir()
+	}
+	// общая память нужна, иначе каждое соединение пула видит свою пустую базу
+	cfg.DatabasePath = fmt.Sprintf("file:servertest%d?mode=memory&cache=shared", dbSeq.Add(1))
+
+	store, err := storage.NewStorage(cfg.DatabasePath)
+	if err != nil {
+		t.Fatalf("servertest: open storage: %v", err)
+	}
+	if err := store.Migrate(); err != nil {
+		store.Close()
+		t.Fatalf("servertest: migrate: %v", err)
+	}
+
+	listener, err := net.Listen("tcp4", cfg.Host+":0")
+	if err != nil {
+		store.Close()
+		t.Fatalf("servertest: listen: %v", err)
+	}
+	cfg.Port = listener.Addr().(*net.TCPAddr).Port
+	srv, err := server.New(cfg, store)
+	if err != nil {
+		listener.Close()
+		store.Close()
+		t.Fatalf("servertest: %v", err)
+	}
+	scheme := "http://"
+	var tlsConfig *tls.Config
+	if len(cfg.TLSCerts) > 0 {
+		// сертификаты в тестах самоподписанные, проверку цепочки тесты делают сами
+		scheme = "https://"
+		tlsConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	s := &Server{
+		URL:   scheme + listener.Addr().String(),
+		Addr:  listener.Addr().String(),
+		Cfg:   cfg,
+		Store: store,
+		srv:   srv,
+		// сжатие и редиректы не прячем — тесты проверяют ответ как есть
+		client: &http.Client{
+			Transport: &http.Transport{DisableCompression: true, TLSClientConfig: tlsConfig},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		done:      make(chan error, 1),
+		tlsConfig: tlsConfig,
+	}
+	go func() { s.done <- s.srv.Serve(listener) }()
+	t.Cleanup(func() { s.Close(t) })
+	return s
+}
+
+// Close останавливает сервер и закрывает базу. Вызывается автоматически
+func (s *Server) Close(t testing.TB) {
+	if s.srv == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.Cfg.ShutdownTimeout)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Errorf("servertest: %v", err)
+	}
+}
+
+// Shutdown останавливает сервер с дедлайном ctx и возвращает ошибку
+// остановки — для тестов самой остановки. После него Close ничего не делает
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.srv == nil {
+		return nil
+	}
+	s.client.CloseIdleConnections()
+	err := s.srv.Shutdown(ctx)
+	if err != nil {
+		err = fmt.Errorf("shutdown: %w", err)
+	}
+	if serveErr := <-s.done; serveErr != nil {
+		err = errors.Join(err, fmt.Errorf("serve: %w", serveErr))
+	}
+	s.srv = nil
+	return err
+}
+
+// Token выдаёт JWT, подписанный секретом сервера
+func (s *Server) Token(t testing.TB, userID int) string {
+	t.Helper()
+	token, err := jwt.GenerateToken(userID, s.Cfg)
+	if err != nil {
+		t.Fatalf("servertest: token: %v", err)
+	}
+	return token
+}
+
+// Dial открывает сырое соединение — для проверок на уровне протокола.
+// У сервера с TLS рукопожатие уже пройдено
+func (s *Server) Dial(t testing.TB) net.Conn {
+	t.Helper()
+	var conn net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		conn, err = tls.Dial("tcp4", s.Addr, s.tlsConfig)
+	} else {
+		conn, err = net.Dial("tcp4", s.Addr)
+	}
+	if err != nil {
+		t.Fatalf("servertest: dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// WriteCert создаёт самоподписанный сертификат на hosts во временном
+// каталоге теста — для Config.TLSCerts
+func WriteCert(t testing.TB, hosts ...string) config.TLSCert {
+	t.Helper()
+	certPEM, keyPEM, err := certs.SelfSigned(hosts, time.Hour)
+	if err != nil {
+		t.Fatalf("servertest: certificate: %v", err)
+	}
+	dir := t.TempDir()
+	pair := config.TLSCert{
+		CertFile: filepath.Join(dir, "cert.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+	}
+	if err := os.WriteFile(pair.CertFile, certPEM, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pair.KeyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return pair
+}
+
+// WithClientCert возвращает тот же сервер с клиентом, который предъявляет
+// cert при рукопожатии — для проверок mTLS
+func (s *Server) WithClientCert(cert tls.Certificate) *Server {
+	tlsConfig := s.tlsConfig.Clone()
+	tlsConfig.Certificates = []tls.Certificate{cert}
+	c := *s
+	c.tlsConfig = tlsConfig
+	c.client = &http.Client{
+		Transport:     &http.Transport{DisableCompression: true, TLSClientConfig: tlsConfig},
+		CheckRedirect: s.client.CheckRedirect,
+	}
+	return &c
+}
+
+// Do отправляет запрос. path — путь с query, header — пары имя, значение
+func (s *Server) Do(t testing.TB, method, path string, body []byte, header ...string) *Response {
+	t.Helper()
+	if len(header)%2 != 0 {
+		t.Fatalf("servertest: odd header list %q", header)
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		t.Fatalf("servertest: %s %s: %v", method, path, err)
+	}
+	for i := 0; i < len(header); i += 2 {
+		req.Header.Add(header[i], header[i+1])
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		t.Fatalf("servertest: %s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("servertest: %s %s: read body: %v", method, path, err)
+	}
+	return &Response{
+		Status: resp.StatusCode,
+		Header: httpx.Header(resp.Header),
+		Body:   data,
+		req:    method + " " + path,
+	}
+}
+
+// JSON отправляет v в теле как application/json
+func (s *Server) JSON(t testing.TB, method, path string, v any, header ...string) *Response {
+	t.Helper()
+	body, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("servertest: marshal: %v", err)
+	}
+	return s.Do(t, method, path, body, append([]string{"Content-Type", "application/json"}, header...)...)
+}
+
+// Response — прочитанный ответ с методами проверки.
+// Методы Assert* возвращают сам ответ, чтобы проверки шли цепочкой
+type Response struct {
+	Status int
+	Header httpx.Header
+	Body   []byte
+	req    string
+}
+
+// AssertStatus останавливает тест, если статус не совпал
+func (r *Response) AssertStatus(t testing.TB, want int) *Response {
+	t.Helper()
+	if r.Status != want {
+		t.Fatalf("%s: status %d, want %d; body: %s", r.req, r.Status, want, r.Body)
+	}
+	return r
+}
+
+// AssertHeader проверяет значение заголовка, "" — заголовка быть не должно
+func (r *Response) AssertHeader(t testing.TB, name, want string) *Response {
+	t.Helper()
+	if got := r.Header.Get(name); got != want {
+		t.Fatalf("%s: header %s = %q, want %q", r.req, name, got, want)
+	}
+	return r
+}
+
+// Decode разбирает JSON-тело в v
+func (r *Response) Decode(t testing.TB, v any) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		t.Fatalf("%s: decode %q: %v", r.req, r.Body, err)
+	}
+}
@@ -0,0 +1,183 @@
+package servertest
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"web-server/internal/httpx"
+)
+
+// WebSocket — минимальный клиент WebSocket для тестов. Кадры пишутся
+// как есть, поэтому можно слать и заведомо неверные
+type WebSocket struct {
+	conn io.ReadWriteCloser
+	r    *bufio.Reader
+}
+
+// WebSocket выполняет рукопожатие на path. header — пары имя, значение
+// поверх стандартных заголовков рукопожатия, пустое значение убирает
+// заголовок. Если ответ не 101, клиент nil
+func (s *Server) WebSocket(t testing.TB, path string, header ...string) (*WebSocket, *Response) {
+	t.Helper()
+	key := make([]byte, 16)
+	rand.Read(key)
+	h := httpx.Header{}
+	h.Set("Host", s.Addr)
+	h.Set("Connection", "Upgrade")
+	h.Set("Upgrade", "websocket")
+	h.Set("Sec-WebSocket-Version", "13")
+	h.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+	for i := 0; i+1 < len(header); i += 2 {
+		if header[i+1] == "" {
+			h.Del(header[i])
+		} else {
+			h.Set(header[i], header[i+1])
+		}
+	}
+
+	conn := s.Dial(t)
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	head := "GET " + path + " HTTP/1.1\r\n"
+	for name, values := range h {
+		for _, v := range values {
+			head += name + ": " + v + "\r\n"
+		}
+	}
+	if _, err := io.WriteString(conn, head+"\r\n"); err != nil {
+		t.Fatalf("servertest: websocket handshake: %v", err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("servertest: websocket handshake: %v", err)
+	}
+	var body []byte
+	if resp.StatusCode != 101 {
+		body, _ = io.ReadAll(resp.Body)
+	}
+	resp.Body.Close()
+	result := &Response{Status: resp.StatusCode, Header: httpx.Header(resp.Header), Body: body, req: "WS " + path}
+	if resp.StatusCode != 101 {
+		return nil, result
+	}
+	return &WebSocket{conn: conn, r: r}, result
+}
+
+// WriteFrame отправляет кадр с маской, как положено клиенту.
+// masked == false — заведомо неверный кадр без маски
+func (ws *WebSocket) WriteFrame(t testing.TB, fin bool, opcode byte, payload []byte, masked bool) {
+	t.Helper()
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	var maskBit byte
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	data := append([]byte(nil), payload...)
+	if masked {
+		mask := make([]byte, 4)
+		rand.Read(mask)
+		frame = append(frame, mask...)
+		for i := range data {
+			data[i] ^= mask[i%4]
+		}
+	}
+	if _, err := ws.conn.Write(append(frame, data...)); err != nil {
+		t.Fatalf("servertest: websocket write: %v", err)
+	}
+}
+
+// WriteClose отправляет close-кадр с кодом
+func (ws *WebSocket) WriteClose(t testing.TB, code int, reason string) {
+	t.Helper()
+	ws.WriteFrame(t, true, 0x8, append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...), true)
+}
+
+// ReadFrame читает кадр сервера. Сервер кадры не маскирует
+func (ws *WebSocket) ReadFrame(t testing.TB) (fin bool, opcode byte, payload []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(ws.r, head[:]); err != nil {
+		t.Fatalf("servertest: websocket read: %v", err)
+	}
+	if head[1]&0x80 != 0 {
+		t.Fatalf("servertest: server frame is masked")
+	}
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(ws.r, ext[:])
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(ws.r, ext[:])
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(ws.r, payload); err != nil {
+		t.Fatalf("servertest: websocket read: %v", err)
+	}
+	return head[0]&0x80 != 0, head[0] & 0x0f, payload
+}
+
+// ReadMessage читает сообщение данных, пропуская ping, и собирает фрагменты
+func (ws *WebSocket) ReadMessage(t testing.TB) (opcode byte, data []byte) {
+	t.Helper()
+	for {
+		fin, op, payload := ws.ReadFrame(t)
+		switch op {
+		case 0x9, 0xA:
+			continue
+		case 0x8:
+			t.Fatalf("servertest: unexpected close %q", payload)
+		case 0x1, 0x2:
+			opcode = op
+		}
+		data = append(data, payload...)
+		if fin {
+			return opcode, data
+		}
+	}
+}
+
+// ReadClose ждёт close-кадр сервера, пропуская остальные, и возвращает код
+func (ws *WebSocket) ReadClose(t testing.TB) (code int, reason string) {
+	t.Helper()
+	for {
+		_, op, payload := ws.ReadFrame(t)
+		if op != 0x8 {
+			continue
+		}
+		if len(payload) < 2 {
+			return 1005, ""
+		}
+		return int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+	}
+}
+
+// Closed сообщает, закрыл ли сервер TCP-соединение
+func (ws *WebSocket) Closed() bool {
+	_, err := ws.r.ReadByte()
+	return err != nil
+}
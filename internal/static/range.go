@@ -0,0 +1,147 @@
+package static
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"web-server/internal/httpx"
+	"web-server/pkg/logger"
+)
+
+// maxRanges — больше диапазонов в одном Range не обслуживаем,
+// чтобы запрос из тысяч мелких кусков не стал усилителем нагрузки
+const maxRanges = 16
+
+var errUnsatisfiable = errors.New("range not satisfiable")
+
+// byteRange — диапазон байт файла
+type byteRange struct {
+	start, length int64
+}
+
+func (br byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", br.start, br.start+br.length-1, size)
+}
+
+// parseRange разбирает заголовок Range для файла размером size (RFC 9110, 14.1.2).
+// Некорректный или чрезмерный заголовок игнорируется — (nil, nil), файл
+// отдаётся целиком. Если ни один диапазон не попадает в файл — errUnsatisfiable
+func parseRange(header string, size int64) ([]byteRange, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return nil, nil
+	}
+	var ranges []byteRange
+	var total int64
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, nil
+		}
+
+		var br byteRange
+		if first == "" {
+			// "-n" — последние n байт
+			n, err := parseOffset(last)
+			if err != nil {
+				return nil, nil
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			n = min(n, size)
+			br = byteRange{start: size - n, length: n}
+		} else {
+			start, err := parseOffset(first)
+			if err != nil {
+				return nil, nil
+			}
+			end := size - 1
+			if last != "" {
+				if end, err = parseOffset(last); err != nil || end < start {
+					return nil, nil
+				}
+			}
+			if start >= size {
+				continue
+			}
+			end = min(end, size-1)
+			br = byteRange{start: start, length: end - start + 1}
+		}
+
+		ranges = append(ranges, br)
+		total += br.length
+		if len(ranges) > maxRanges || total > size {
+			return nil, nil
+		}
+	}
+	if len(ranges) == 0 {
+		return nil, errUnsatisfiable
+	}
+	return ranges, nil
+}
+
+func parseOffset(s string) (int64, error) {
+	if s == "" || strings.Trim(s, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid range offset %q", s)
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// checkIfRange — можно ли применять Range: без If-Range всегда, с ним —
+// только если сильный ETag или дата изменения совпадают точно (RFC 9110, 13.1.5).
+// ETag сжатого ответа ("...-gzip") подходит: клиент получил именно его
+func checkIfRange(r *httpx.Request, etag string, info os.FileInfo) bool {
+	ifRange := r.Headers.Get("If-Range")
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return httpx.StrongETagMatch(ifRange, etag)
+	}
+	date, err := httpx.ParseTime(ifRange)
+	return err == nil && info.ModTime().Truncate(time.Second).Equal(date)
+}
+
+// sendMultipart отдаёт несколько диапазонов как multipart/byteranges.
+// Длина известна заранее, поэтому ответ идёт с Content-Length
+func sendMultipart(w *httpx.ResponseWriter, r *httpx.Request, f io.ReaderAt, ranges []byteRange, size int64, contentType string) {
+	var b [12]byte
+	rand.Read(b[:])
+	boundary := hex.EncodeToString(b[:])
+
+	headers := make([]string, len(ranges))
+	length := int64(len("\r\n--" + boundary + "--\r\n"))
+	for i, br := range ranges {
+		headers[i] = fmt.Sprintf("\r\n--%s\r\nContent-Type: %s\r\nContent-Range: %s\r\n\r\n",
+			boundary, contentType, br.contentRange(size))
+		length += int64(len(headers[i])) + br.length
+	}
+
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	w.WriteHeader(206)
+	if r.Method == "HEAD" {
+		return
+	}
+	for i, br := range ranges {
+		if _, err := io.WriteString(w, headers[i]); err != nil {
+			return
+		}
+		if _, err := io.Copy(w, io.NewSectionReader(f, br.start, br.length)); err != nil {
+			logger.Log.Warn("ошибка отправки файла", "path", r.URL.Path, "error", err)
+			return
+		}
+	}
+	io.WriteString(w, "\r\n--"+boundary+"--\r\n")
+}
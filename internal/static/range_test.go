@@ -0,0 +1,45 @@
+package static
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	const size = 100
+	tests := []struct {
+		header string
+		want   []byteRange
+		err    error
+	}{
+		{"", nil, nil},
+		{"bytes=0-9", []byteRange{{0, 10}}, nil},
+		{"bytes=90-", []byteRange{{90, 10}}, nil},
+		{"bytes=-5", []byteRange{{95, 5}}, nil},
+		{"bytes=-500", []byteRange{{0, 100}}, nil},
+		{"bytes=95-200", []byteRange{{95, 5}}, nil},
+		{"bytes=0-0, 10-19", []byteRange{{0, 1}, {10, 10}}, nil},
+		// недостижимые диапазоны пропускаются, если есть другие
+		{"bytes=0-0,200-300", []byteRange{{0, 1}}, nil},
+		{"bytes=100-", nil, errUnsatisfiable},
+		{"bytes=-0", nil, errUnsatisfiable},
+		// некорректные и чрезмерные — игнорируются целиком
+		{"items=0-9", nil, nil},
+		{"bytes=9-0", nil, nil},
+		{"bytes=a-b", nil, nil},
+		{"bytes=+1-2", nil, nil},
+		{"bytes=0-9;x", nil, nil},
+		{"bytes=0-99,0-99", nil, nil},
+		{"bytes=0-0,1-1,2-2,3-3,4-4,5-5,6-6,7-7,8-8,9-9,10-10,11-11,12-12,13-13,14-14,15-15,16-16", nil, nil},
+	}
+	for _, tt := range tests {
+		got, err := parseRange(tt.header, size)
+		if err != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseRange(%q) = %v, %v; want %v, %v", tt.header, got, err, tt.want, tt.err)
+		}
+	}
+
+	if _, err := parseRange("bytes=0-", 0); err != errUnsatisfiable {
+		t.Errorf("empty file: err = %v, want errUnsatisfiable", err)
+	}
+}
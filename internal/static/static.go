@@ -0,0 +1,251 @@
+// Package static отдаёт файлы из каталога: SPA-сборку, документацию.
+// Путь не выходит за корень ни через "..", ни через симлинки
+package static
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+	"web-server/internal/httpx"
+	"web-server/pkg/logger"
+)
+
+// PathParam — имя параметра маршрута с путём файла: "GET /docs/{path...}"
+const PathParam = "path"
+
+// FileServer отдаёт файлы из одного корневого каталога
+type FileServer struct {
+	root  *os.Root
+	index []string
+}
+
+// New открывает каталог dir. index — имена index-файлов каталога
+// в порядке приоритета, по умолчанию index.html
+func New(dir string, index ...string) (*FileServer, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(index) == 0 {
+		index = []string{"index.html"}
+	}
+	return &FileServer{root: root, index: index}, nil
+}
+
+// Close закрывает корневой каталог
+func (s *FileServer) Close() error {
+	return s.root.Close()
+}
+
+// Serve отдаёт файл по параметру маршрута PathParam
+func (s *FileServer) Serve(w *httpx.ResponseWriter, r *httpx.Request) {
+	name := r.Param(PathParam)
+	if name == "" {
+		name = "."
+	}
+	// скрытые файлы (.env, .git) не отдаём никогда
+	if !filepath.IsLocal(name) || hasHiddenSegment(name) {
+		w.WriteHeader(404)
+		return
+	}
+
+	f, info, err := s.open(name)
+	if err != nil {
+		s.sendError(w, name, err)
+		return
+	}
+	defer f.Close()
+
+	if info.IsDir() {
+		// относительные ссылки в index.html работают только со слешем на конце
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			location := r.URL.RawPath + "/"
+			if r.URL.RawQuery != "" {
+				location += "?" + r.URL.RawQuery
+			}
+			w.Header().Set("Location", location)
+			w.WriteHeader(301)
+			return
+		}
+		f.Close()
+		if f, info, name, err = s.openIndex(name); err != nil {
+			s.sendError(w, name, err)
+			return
+		}
+		defer f.Close()
+	}
+
+	s.serveFile(w, r, f, info, name)
+}
+
+// open открывает файл внутри корня. Не обычные файлы и каталоги — как не найденные
+func (s *FileServer) open(name string) (*os.File, os.FileInfo, error) {
+	f, err := s.root.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() && !info.IsDir() {
+		f.Close()
+		return nil, nil, os.ErrNotExist
+	}
+	return f, info, nil
+}
+
+// openIndex ищет index-файл каталога. Списки файлов не отдаём
+func (s *FileServer) openIndex(dir string) (*os.File, os.FileInfo, string, error) {
+	for _, index := range s.index {
+		name := path.Join(dir, index)
+		f, info, err := s.open(name)
+		if err == nil && !info.IsDir() {
+			return f, info, name, nil
+		}
+		if err == nil {
+			f.Close()
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, nil, name, err
+		}
+	}
+	return nil, nil, dir, os.ErrNotExist
+}
+
+func (s *FileServer) sendError(w *httpx.ResponseWriter, name string, err error) {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		w.WriteHeader(404)
+	case errors.Is(err, os.ErrPermission):
+		w.WriteHeader(403)
+	default:
+		// в том числе попытка выйти из корня по симлинку
+		logger.Log.Warn("ошибка открытия файла", "name", name, "error", err)
+		w.WriteHeader(404)
+	}
+}
+
+// serveFile отдаёт файл целиком или диапазоны из Range.
+// Рядом лежащий name.gz отдаётся вместо оригинала, если клиент принимает gzip
+func (s *FileServer) serveFile(w *httpx.ResponseWriter, r *httpx.Request, f *os.File, info os.FileInfo, name string) {
+	contentType, err := detectContentType(f, name)
+	if err != nil {
+		logger.Log.Error("ошибка чтения файла", "name", name, "error", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	if gz, gzInfo, ok := s.openSidecar(name, info); ok {
+		// ответ зависит от Accept-Encoding, даже если отдаём оригинал
+		w.Header().Add("Vary", "Accept-Encoding")
+		if httpx.NegotiateEncoding(r.Headers.Get("Accept-Encoding")) == "gzip" {
+			defer gz.Close()
+			w.Header().Set("Content-Encoding", "gzip")
+			f, info = gz, gzInfo
+		} else {
+			gz.Close()
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Accept-Ranges", "bytes")
+	etag := fileETag(info)
+	if !httpx.CheckPreconditions(w, r, etag, info.ModTime()) {
+		return
+	}
+
+	size := info.Size()
+	// при несовпавшем If-Range файл изменился — Range игнорируем, отдаём целиком
+	var ranges []byteRange
+	if checkIfRange(r, etag, info) {
+		if ranges, err = parseRange(r.Headers.Get("Range"), size); err != nil {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			w.WriteHeader(416)
+			return
+		}
+	}
+
+	switch len(ranges) {
+	case 0:
+		sendContent(w, r, io.NewSectionReader(f, 0, size), size, 200)
+	case 1:
+		rng := ranges[0]
+		w.Header().Set("Content-Range", rng.contentRange(size))
+		sendContent(w, r, io.NewSectionReader(f, rng.start, rng.length), rng.length, 206)
+	default:
+		sendMultipart(w, r, f, ranges, size, contentType)
+	}
+}
+
+// openSidecar открывает name.gz, если он есть и не старше оригинала
+func (s *FileServer) openSidecar(name string, orig os.FileInfo) (*os.File, os.FileInfo, bool) {
+	f, info, err := s.open(name + ".gz")
+	if err != nil {
+		return nil, nil, false
+	}
+	if info.IsDir() || info.ModTime().Before(orig.ModTime()) {
+		f.Close()
+		return nil, nil, false
+	}
+	return f, info, true
+}
+
+func sendContent(w *httpx.ResponseWriter, r *httpx.Request, content io.Reader, length int64, status int) {
+	w.Header().Set("Content-Length", fmt.Sprint(length))
+	w.WriteHeader(status)
+	if r.Method == "HEAD" {
+		return
+	}
+	if _, err := io.Copy(w, content); err != nil {
+		logger.Log.Warn("ошибка отправки файла", "path", r.URL.Path, "error", err)
+	}
+}
+
+// fileETag — сильный ETag из времени изменения и размера
+func fileETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+// detectContentType определяет тип по расширению, а без него — по первым
+// байтам: текст в UTF-8 или двоичные данные
+func detectContentType(f *os.File, name string) (string, error) {
+	ext := path.Ext(name)
+	if ext == ".gz" {
+		// file.tar.gz — это архив, а не сжатый tar
+		return "application/gzip", nil
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t, nil
+	}
+
+	buf := make([]byte, 512)
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	buf = buf[:n]
+	// последний символ мог разрезаться на границе буфера
+	for i := 0; i < utf8.UTFMax && len(buf) > 0 && !utf8.Valid(buf); i++ {
+		buf = buf[:len(buf)-1]
+	}
+	if utf8.Valid(buf) && !strings.ContainsRune(string(buf), 0) {
+		return "text/plain; charset=utf-8", nil
+	}
+	return "application/octet-stream", nil
+}
+
+func hasHiddenSegment(name string) bool {
+	for _, seg := range strings.Split(name, "/") {
+		if strings.HasPrefix(seg, ".") && seg != "." {
+			return true
+		}
+	}
+	return false
+}
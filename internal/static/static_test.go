@@ -0,0 +1,301 @@
+package static_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"web-server/internal/config"
+	"web-server/internal/servertest"
+)
+
+const appJS = "console.log('hello, static');\n"
+
+// bigText больше CompressMinBytes — сжимается на лету
+var bigText = strings.Repeat("static text ", 500)
+
+// newStaticServer раздаёт временный каталог по префиксу /app
+func newStaticServer(t *testing.T) (*servertest.Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":       "<h1>app</h1>",
+		"app.js":           appJS,
+		"notes":            "просто текст без расширения",
+		"blob":             "\x00\x01\x02",
+		"docs/index.html":  "<h1>docs</h1>",
+		"empty/readme.txt": "no index here",
+		".env":             "SECRET=1",
+		"assets/.git/HEAD": "ref",
+		"assets/logo.svg":  "<svg/>",
+		"digits.txt":       "0123456789",
+		"archive.tar.gz":   "not really gzip",
+		"big.txt":          bigText,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(appJS))
+	zw.Close()
+	if err := os.WriteFile(filepath.Join(dir, "app.js.gz"), gz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := servertest.New(t, func(cfg *config.Config) {
+		cfg.StaticMounts = []config.StaticMount{{Prefix: "/app", Dir: dir}}
+		cfg.StaticIndex = []string{"index.html"}
+	})
+	return s, dir
+}
+
+func TestServeFiles(t *testing.T) {
+	s, _ := newStaticServer(t)
+
+	s.Do(t, "GET", "/app/app.js", nil).
+		AssertStatus(t, 200).
+		AssertHeader(t, "Content-Type", mime.TypeByExtension(".js")).
+		AssertHeader(t, "Content-Length", "30").
+		AssertHeader(t, "Accept-Ranges", "bytes")
+	s.Do(t, "GET", "/app/notes", nil).AssertHeader(t, "Content-Type", "text/plain; charset=utf-8")
+	s.Do(t, "GET", "/app/blob", nil).AssertHeader(t, "Content-Type", "application/octet-stream")
+	s.Do(t, "GET", "/app/archive.tar.gz", nil).
+		AssertHeader(t, "Content-Type", "application/gzip").
+		AssertHeader(t, "Content-Encoding", "")
+
+	// index-файлы и редирект каталога на путь со слешем
+	if body := s.Do(t, "GET", "/app/", nil).AssertStatus(t, 200).Body; string(body) != "<h1>app</h1>" {
+		t.Errorf("GET /app/ = %q", body)
+	}
+	s.Do(t, "GET", "/app", nil).AssertStatus(t, 301).AssertHeader(t, "Location", "/app/")
+	s.Do(t, "GET", "/app/docs?v=1", nil).AssertStatus(t, 301).AssertHeader(t, "Location", "/app/docs/?v=1")
+	s.Do(t, "GET", "/app/docs/", nil).AssertStatus(t, 200)
+	s.Do(t, "GET", "/app/empty/", nil).AssertStatus(t, 404)
+
+	// HEAD — те же заголовки без тела
+	head := s.Do(t, "HEAD", "/app/app.js", nil).AssertStatus(t, 200).AssertHeader(t, "Content-Length", "30")
+	if len(head.Body) != 0 {
+		t.Errorf("HEAD body = %q", head.Body)
+	}
+
+	s.Do(t, "POST", "/app/app.js", nil).AssertStatus(t, 405).AssertHeader(t, "Allow", "GET, HEAD, OPTIONS")
+	s.Do(t, "GET", "/app/missing.js", nil).AssertStatus(t, 404)
+}
+
+func TestServeConfinement(t *testing.T) {
+	s, dir := newStaticServer(t)
+
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "link.txt")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if err := os.Symlink(filepath.Dir(outside), filepath.Join(dir, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+	// симлинк внутри корня допустим
+	if err := os.Symlink("digits.txt", filepath.Join(dir, "alias.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{
+		"/app/.env",
+		"/app/assets/.git/HEAD",
+		"/app/link.txt",
+		"/app/linkdir/secret.txt",
+	} {
+		if resp := s.Do(t, "GET", path, nil); resp.Status != 404 {
+			t.Errorf("GET %s = %d %q, want 404", path, resp.Status, resp.Body)
+		}
+	}
+	s.Do(t, "GET", "/app/alias.txt", nil).AssertStatus(t, 200)
+
+	// ".." нормализуется до роутера и не выходит за префикс
+	conn := s.Dial(t)
+	io.WriteString(conn, "GET /app/../../etc/passwd HTTP/1.1\r\nHost: x\r\nConnection: close\r\n\r\n")
+	reply, _ := io.ReadAll(conn)
+	if !bytes.HasPrefix(reply, []byte("HTTP/1.1 404")) {
+		t.Errorf("traversal reply = %q", reply)
+	}
+	s.Do(t, "GET", "/app/%2e%2e/%2e%2e/etc/passwd", nil).AssertStatus(t, 400)
+}
+
+func TestServeConditional(t *testing.T) {
+	s, dir := newStaticServer(t)
+
+	resp := s.Do(t, "GET", "/app/digits.txt", nil).AssertStatus(t, 200)
+	etag := resp.Header.Get("ETag")
+	modified := resp.Header.Get("Last-Modified")
+	if etag == "" || modified == "" {
+		t.Fatalf("validators missing: ETag %q, Last-Modified %q", etag, modified)
+	}
+
+	s.Do(t, "GET", "/app/digits.txt", nil, "If-None-Match", etag).AssertStatus(t, 304)
+	s.Do(t, "GET", "/app/digits.txt", nil, "If-Modified-Since", modified).AssertStatus(t, 304)
+
+	// после изменения файла старые валидаторы не подходят
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "digits.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	s.Do(t, "GET", "/app/digits.txt", nil, "If-None-Match", etag).AssertStatus(t, 200)
+	s.Do(t, "GET", "/app/digits.txt", nil, "If-Match", etag).AssertStatus(t, 412)
+}
+
+func TestServeRange(t *testing.T) {
+	s, _ := newStaticServer(t)
+	path := "/app/digits.txt"
+
+	resp := s.Do(t, "GET", path, nil, "Range", "bytes=2-4").
+		AssertStatus(t, 206).
+		AssertHeader(t, "Content-Range", "bytes 2-4/10").
+		AssertHeader(t, "Content-Length", "3")
+	if string(resp.Body) != "234" {
+		t.Errorf("bytes=2-4 body = %q", resp.Body)
+	}
+	if resp := s.Do(t, "GET", path, nil, "Range", "bytes=-3").AssertStatus(t, 206); string(resp.Body) != "789" {
+		t.Errorf("bytes=-3 body = %q", resp.Body)
+	}
+	s.Do(t, "GET", path, nil, "Range", "bytes=10-").
+		AssertStatus(t, 416).
+		AssertHeader(t, "Content-Range", "bytes */10")
+	s.Do(t, "GET", path, nil, "Range", "lines=1-2").AssertStatus(t, 200)
+
+	// If-Range: диапазон только при точном совпадении валидатора
+	full := s.Do(t, "GET", path, nil)
+	etag, modified := full.Header.Get("ETag"), full.Header.Get("Last-Modified")
+	s.Do(t, "GET", path, nil, "Range", "bytes=0-0", "If-Range", etag).AssertStatus(t, 206)
+	s.Do(t, "GET", path, nil, "Range", "bytes=0-0", "If-Range", modified).AssertStatus(t, 206)
+	s.Do(t, "GET", path, nil, "Range", "bytes=0-0", "If-Range", `"stale"`).AssertStatus(t, 200)
+	s.Do(t, "GET", path, nil, "Range", "bytes=0-0", "If-Range", "W/"+etag).AssertStatus(t, 200)
+	s.Do(t, "GET", path, nil, "Range", "bytes=0-0", "If-Range", "Mon, 02 Jan 2006 15:04:05 GMT").AssertStatus(t, 200)
+
+	// несколько диапазонов — multipart/byteranges
+	resp = s.Do(t, "GET", path, nil, "Range", "bytes=0-1,8-").AssertStatus(t, 206)
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+	mr := multipart.NewReader(bytes.NewReader(resp.Body), params["boundary"])
+	for _, want := range []struct{ contentRange, body string }{
+		{"bytes 0-1/10", "01"},
+		{"bytes 8-9/10", "89"},
+	} {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("next part: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		if got := part.Header.Get("Content-Range"); got != want.contentRange || string(body) != want.body {
+			t.Errorf("part = %q %q, want %q %q", got, body, want.contentRange, want.body)
+		}
+		if got := part.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+			t.Errorf("part Content-Type = %q", got)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("extra part: %v", err)
+	}
+}
+
+func TestServePrecompressed(t *testing.T) {
+	s, _ := newStaticServer(t)
+
+	gz := s.Do(t, "GET", "/app/app.js", nil, "Accept-Encoding", "gzip").
+		AssertStatus(t, 200).
+		AssertHeader(t, "Content-Encoding", "gzip").
+		AssertHeader(t, "Vary", "Accept-Encoding").
+		AssertHeader(t, "Content-Type", mime.TypeByExtension(".js"))
+	zr, err := gzip.NewReader(bytes.NewReader(gz.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != appJS {
+		t.Errorf("decompressed = %q", body)
+	}
+
+	plain := s.Do(t, "GET", "/app/app.js", nil, "Accept-Encoding", "identity").
+		AssertStatus(t, 200).
+		AssertHeader(t, "Content-Encoding", "").
+		AssertHeader(t, "Vary", "Accept-Encoding")
+	if string(plain.Body) != appJS {
+		t.Errorf("plain body = %q", plain.Body)
+	}
+	if gz.Header.Get("ETag") == plain.Header.Get("ETag") {
+		t.Errorf("gzip and identity share ETag %q", gz.Header.Get("ETag"))
+	}
+
+	// диапазон сжатого представления — это байты .gz, а не исходника
+	s.Do(t, "GET", "/app/app.js", nil, "Accept-Encoding", "gzip", "Range", "bytes=0-1").
+		AssertStatus(t, 206).
+		AssertHeader(t, "Content-Encoding", "gzip").
+		AssertHeader(t, "Content-Range", "bytes 0-1/"+gz.Header.Get("Content-Length"))
+}
+
+// HEAD решает о сжатии так же, как GET, хотя тело не пишет
+func TestServeCompressedHead(t *testing.T) {
+	s, _ := newStaticServer(t)
+
+	get := s.Do(t, "GET", "/app/big.txt", nil, "Accept-Encoding", "gzip").
+		AssertStatus(t, 200).
+		AssertHeader(t, "Content-Encoding", "gzip")
+	etag := get.Header.Get("ETag")
+	if !strings.HasSuffix(etag, `-gzip"`) {
+		t.Fatalf("GET ETag = %q", etag)
+	}
+	if get.Header.Get("Content-Length") != fmt.Sprint(len(get.Body)) || len(get.Body) >= len(bigText) {
+		t.Fatalf("GET Content-Length = %q, body %d bytes", get.Header.Get("Content-Length"), len(get.Body))
+	}
+
+	// сжатый размер без тела не узнать — HEAD идёт без Content-Length,
+	// но и без несжатого
+	head := s.Do(t, "HEAD", "/app/big.txt", nil, "Accept-Encoding", "gzip").
+		AssertStatus(t, 200).
+		AssertHeader(t, "Content-Encoding", "gzip").
+		AssertHeader(t, "ETag", etag).
+		AssertHeader(t, "Vary", "Accept-Encoding").
+		AssertHeader(t, "Content-Length", "")
+	if len(head.Body) != 0 {
+		t.Errorf("HEAD body = %q", head.Body)
+	}
+
+	// без Accept-Encoding оба отдают исходный размер
+	s.Do(t, "HEAD", "/app/big.txt", nil).
+		AssertHeader(t, "Content-Encoding", "").
+		AssertHeader(t, "Content-Length", fmt.Sprint(len(bigText)))
+}
+
+// If-Range с ETag сжатого ответа — тот же файл, диапазон применяется
+func TestServeRangeCompressedETag(t *testing.T) {
+	s, _ := newStaticServer(t)
+
+	etag := s.Do(t, "GET", "/app/big.txt", nil, "Accept-Encoding", "gzip").
+		AssertHeader(t, "Content-Encoding", "gzip").
+		Header.Get("ETag")
+	resp := s.Do(t, "GET", "/app/big.txt", nil, "Range", "bytes=0-5", "If-Range", etag).
+		AssertStatus(t, 206).
+		AssertHeader(t, "Content-Range", fmt.Sprintf("bytes 0-5/%d", len(bigText)))
+	if string(resp.Body) != bigText[:6] {
+		t.Errorf("range body = %q", resp.Body)
+	}
+	// слабый тег для If-Range не годится и с суффиксом
+	s.Do(t, "GET", "/app/big.txt", nil, "Range", "bytes=0-5", "If-Range", "W/"+etag).AssertStatus(t, 200)
+}
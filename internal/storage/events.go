@@ -0,0 +1,55 @@
+package storage
+
+import "web-server/internal/model"
+
+// типы событий пользователей
+const (
+	UserCreated = "created"
+	UserUpdated = "updated"
+	UserDeleted = "deleted"
+)
+
+// subscriberBuffer — сколько событий подписчик может не разобрать,
+// прежде чем его отключат
+const subscriberBuffer = 64
+
+// UserEvent — изменение пользователя, о котором Storage сообщает подписчикам
+type UserEvent struct {
+	Type string      `json:"type"`
+	ID   int         `json:"id"`
+	User *model.User `json:"user,omitempty"` // nil у deleted
+}
+
+// Subscribe подписывает на события пользователей. Канал закрывается после
+// cancel или если подписчик не успевает их разбирать: пропущенное уже
+// не восстановить, и подписчику нужно перечитать список заново
+func (s *Storage) Subscribe() (<-chan UserEvent, func()) {
+	ch := make(chan UserEvent, subscriberBuffer)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subs[ch]; ok {
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// publish рассылает событие, не блокируясь на медленных подписчиках
+func (s *Storage) publish(ev UserEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package storage
 
 import (
 	"database/sql"
+	"sync"
+	"time"
 	"web-server/pkg/logger"
 
 	_ "modernc.org/sqlite"
@@ -9,6 +11,9 @@ import (
 
 type Storage struct {
 	db *sql.DB
+
+	mu   sync.Mutex
+	subs map[chan UserEvent]struct{} // подписчики на изменения пользователей
 }
 
 func NewStorage(dbPath string) (*Storage, error) {
@@ -22,10 +27,11 @@ func NewStorage(dbPath string) (*Storage, error) {
 	}
 
 	logger.Log.Info("✅ Подключено к SQLite", "path", dbPath)
-	return &Storage{db: db}, nil
+	return &Storage{db: db, subs: make(map[chan UserEvent]struct{})}, nil
 }
 
-// Migrate создаёт таблицы, если их нет
+// Migrate создаёт таблицы, если их нет, и добавляет столбцы,
+// которых не хватает в базе от старой версии
 func (s *Storage) Migrate() error {
 	queries := []string{
 		`CREATE TABLE IF NOT EXISTS user (
@@ -33,7 +39,10 @@ func (s *Storage) Migrate() error {
 			Username TEXT NOT NULL,
 			Role TEXT NOT NULL,
 			Login TEXT NOT NULL UNIQUE,
-			Password TEXT NOT NULL
+			Password TEXT NOT NULL,
+			Version INTEGER NOT NULL DEFAULT 1,
+			CreatedAt INTEGER NOT NULL DEFAULT 0,
+			UpdatedAt INTEGER NOT NULL DEFAULT 0
 		);`,
 	}
 
@@ -43,6 +52,56 @@ func (s *Storage) Migrate() error {
 		}
 	}
 
+	// версия и время изменения появились позже, время в unix-миллисекундах
+	added := false
+	for _, col := range []struct{ name, def string }{
+		{"Version", "INTEGER NOT NULL DEFAULT 1"},
+		{"CreatedAt", "INTEGER NOT NULL DEFAULT 0"},
+		{"UpdatedAt", "INTEGER NOT NULL DEFAULT 0"},
+	} {
+		ok, err := s.hasColumn("user", col.name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		if _, err := s.db.Exec("ALTER TABLE user ADD COLUMN " + col.name + " " + col.def); err != nil {
+			return err
+		}
+		added = true
+	}
+	if added {
+		// у старых строк времени нет — считаем их созданными сейчас
+		now := time.Now().UnixMilli()
+		if _, err := s.db.Exec("UPDATE user SET CreatedAt = ?, UpdatedAt = ? WHERE UpdatedAt = 0", now, now); err != nil {
+			return err
+		}
+	}
+
 	logger.Log.Info("✅ Миграции применены")
 	return nil
 }
+
+func (s *Storage) hasColumn(table, column string) (bool, error) {
+	rows, err := s.db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// Close закрывает соединение с базой
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"web-server/internal/model"
+)
+
+// база от версии без Version/CreatedAt/UpdatedAt мигрирует на месте
+func TestMigrateOldSchema(t *testing.T) {
+	s, err := NewStorage(filepath.Join(t.TempDir(), "old.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	_, err = s.db.Exec(`CREATE TABLE user (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		Username TEXT NOT NULL,
+		Role TEXT NOT NULL,
+		Login TEXT NOT NULL UNIQUE,
+		Password TEXT NOT NULL
+	);
+	INSERT INTO user (Username, Role, Login, Password) VALUES ('old', 'admin', 'old', 'x');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Migrate(); err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+	u, ok, err := s.GetUser(1)
+	if err != nil || !ok {
+		t.Fatalf("GetUser: %v, %v", ok, err)
+	}
+	if u.Version != 1 || u.UpdatedAt.IsZero() || u.UpdatedAt.Year() < 2000 {
+		t.Fatalf("migrated user = %+v", u)
+	}
+}
+
+func TestUpdateUserVersion(t *testing.T) {
+	s, err := NewStorage(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := s.CreateUser(model.User{Username: "a", Role: "user", Login: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u.Username = "b"
+	if _, _, err := s.UpdateUser(u, 2); err != ErrVersionMismatch {
+		t.Fatalf("stale update: %v", err)
+	}
+	updated, ok, err := s.UpdateUser(u, 1)
+	if err != nil || !ok || updated.Version != 2 || updated.Login != "a" || updated.Username != "b" {
+		t.Fatalf("update = %+v, %v, %v", updated, ok, err)
+	}
+	if _, ok, err := s.UpdateUser(model.User{ID: 99}, 1); ok || err != nil {
+		t.Fatalf("missing user: %v, %v", ok, err)
+	}
+	if _, err := s.DeleteUser(u.ID, 1); err != ErrVersionMismatch {
+		t.Fatalf("stale delete: %v", err)
+	}
+	if ok, err := s.DeleteUser(u.ID, 0); !ok || err != nil {
+		t.Fatalf("delete: %v, %v", ok, err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	s, err := NewStorage(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, cancel := s.Subscribe()
+	defer cancel()
+	slow, _ := s.Subscribe()
+
+	u, err := s.CreateUser(model.User{Username: "a", Role: "user", Login: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := <-events; ev.Type != UserCreated || ev.ID != u.ID || ev.User.Username != "a" {
+		t.Fatalf("event = %+v", ev)
+	}
+	if _, err := s.DeleteUser(u.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if ev := <-events; ev.Type != UserDeleted || ev.ID != u.ID || ev.User != nil {
+		t.Fatalf("event = %+v", ev)
+	}
+
+	// подписчик, который не читает, отключается, когда буфер полон
+	for i := 0; i < subscriberBuffer; i++ {
+		s.publish(UserEvent{Type: UserUpdated, ID: i})
+		<-events
+	}
+	n := 0
+	for range slow {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("slow subscriber got %d events before close, want %d", n, subscriberBuffer)
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("channel open after cancel")
+	}
+	cancel() // повторная отписка безопасна
+}
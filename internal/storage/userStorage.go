@@ -2,12 +2,31 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
+	"time"
 	"web-server/internal/model"
 	"web-server/pkg/logger"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrVersionMismatch — пользователь изменился с версии, которую ждал клиент
+var ErrVersionMismatch = errors.New("user version mismatch")
+
+const userColumns = "ID, Username, Role, Login, Password, Version, CreatedAt, UpdatedAt"
+
+// scanUser читает строку с userColumns
+func scanUser(row interface{ Scan(...any) error }) (model.User, error) {
+	var u model.User
+	var created, updated int64
+	if err := row.Scan(&u.ID, &u.Username, &u.Role, &u.Login, &u.Password, &u.Version, &created, &updated); err != nil {
+		return model.User{}, err
+	}
+	u.CreatedAt = time.UnixMilli(created).UTC()
+	u.UpdatedAt = time.UnixMilli(updated).UTC()
+	return u, nil
+}
+
 func (s *Storage) CreateUser(u model.User) (model.User, error) {
 	hashed, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -15,9 +34,10 @@ func (s *Storage) CreateUser(u model.User) (model.User, error) {
 	}
 	u.Password = string(hashed)
 
+	now := time.Now().UnixMilli()
 	res, err := s.db.Exec(
-		"INSERT INTO user (Username, Role, Login, Password) VALUES (?, ?, ?, ?)",
-		u.Username, u.Role, u.Login, u.Password,
+		"INSERT INTO user (Username, Role, Login, Password, Version, CreatedAt, UpdatedAt) VALUES (?, ?, ?, ?, 1, ?, ?)",
+		u.Username, u.Role, u.Login, u.Password, now, now,
 	)
 	if err != nil {
 		return model.User{}, err
@@ -25,15 +45,17 @@ func (s *Storage) CreateUser(u model.User) (model.User, error) {
 
 	id, _ := res.LastInsertId()
 	u.ID = int(id)
+	u.Version = 1
+	u.CreatedAt = time.UnixMilli(now).UTC()
+	u.UpdatedAt = u.CreatedAt
 
 	logger.Log.Info("создан пользователь", "id", u.ID, "username", u.Username)
+	s.publish(UserEvent{Type: UserCreated, ID: u.ID, User: &u})
 	return u, nil
 }
 
 func (s *Storage) GetUser(id int) (model.User, bool, error) {
-	var u model.User
-	row := s.db.QueryRow("SELECT ID, Username, Role, Login, Password FROM user WHERE ID = ?", id)
-	err := row.Scan(&u.ID, &u.Username, &u.Role, &u.Login, &u.Password)
+	u, err := scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM user WHERE ID = ?", id))
 	if err == sql.ErrNoRows {
 		return model.User{}, false, nil
 	} else if err != nil {
@@ -43,7 +65,7 @@ func (s *Storage) GetUser(id int) (model.User, bool, error) {
 }
 
 func (s *Storage) GetUsers() ([]model.User, error) {
-	rows, err := s.db.Query("SELECT ID, Username, Role, Login, Password FROM user")
+	rows, err := s.db.Query("SELECT " + userColumns + " FROM user")
 	if err != nil {
 		return nil, err
 	}
@@ -51,8 +73,8 @@ func (s *Storage) GetUsers() ([]model.User, error) {
 
 	var users []model.User
 	for rows.Next() {
-		var u model.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.Role, &u.Login, &u.Password); err != nil {
+		u, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
 		users = append(users, u)
@@ -66,7 +88,7 @@ func (s *Storage) GetUsersByRole(role string) ([]model.User, error) {
 	}
 
 	rows, err := s.db.Query(
-		"SELECT ID, Username, Role, Login, Password FROM user WHERE Role = ?",
+		"SELECT "+userColumns+" FROM user WHERE Role = ?",
 		role,
 	)
 	if err != nil {
@@ -77,8 +99,8 @@ func (s *Storage) GetUsersByRole(role string) ([]model.User, error) {
 
 	var users []model.User
 	for rows.Next() {
-		var u model.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.Role, &u.Login, &u.Password); err != nil {
+		u, err := scanUser(rows)
+		if err != nil {
 			logger.Log.Error("ошибка чтения строки пользователя", "error", err)
 			return nil, err
 		}
@@ -88,3 +110,73 @@ func (s *Storage) GetUsersByRole(role string) ([]model.User, error) {
 	logger.Log.Info("получены пользователи по роли", "role", role, "count", len(users))
 	return users, nil
 }
+
+// UpdateUser обновляет имя и роль пользователя. Login и Password
+// меняются, только если переданы. version != 0 — обновить, только если
+// текущая версия совпадает, иначе ErrVersionMismatch.
+// ok == false — пользователя нет
+func (s *Storage) UpdateUser(u model.User, version int) (model.User, bool, error) {
+	if u.Password != "" {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return model.User{}, false, err
+		}
+		u.Password = string(hashed)
+	}
+
+	// проверка версии и запись одним запросом — параллельное изменение не потеряется
+	updated, err := scanUser(s.db.QueryRow(
+		`UPDATE user SET Username = ?, Role = ?,
+			Login = COALESCE(NULLIF(?, ''), Login),
+			Password = COALESCE(NULLIF(?, ''), Password),
+			Version = Version + 1, UpdatedAt = ?
+		WHERE ID = ? AND (? = 0 OR Version = ?)
+		RETURNING `+userColumns,
+		u.Username, u.Role, u.Login, u.Password, time.Now().UnixMilli(), u.ID, version, version,
+	))
+	if err == sql.ErrNoRows {
+		return model.User{}, false, s.versionMismatch(u.ID, version)
+	}
+	if err != nil {
+		return model.User{}, false, err
+	}
+
+	logger.Log.Info("обновлён пользователь", "id", updated.ID, "username", updated.Username, "version", updated.Version)
+	s.publish(UserEvent{Type: UserUpdated, ID: updated.ID, User: &updated})
+	return updated, true, nil
+}
+
+// DeleteUser удаляет пользователя. version != 0 — как в UpdateUser.
+// ok == false — пользователя не было
+func (s *Storage) DeleteUser(id, version int) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM user WHERE ID = ? AND (? = 0 OR Version = ?)", id, version, version)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n == 0 {
+		return false, s.versionMismatch(id, version)
+	}
+	logger.Log.Info("удалён пользователь", "id", id)
+	s.publish(UserEvent{Type: UserDeleted, ID: id})
+	return true, nil
+}
+
+// versionMismatch объясняет, почему условный запрос не затронул строк:
+// пользователь есть, но версия другая — ErrVersionMismatch, нет — nil
+func (s *Storage) versionMismatch(id, version int) error {
+	if version == 0 {
+		return nil
+	}
+	_, ok, err := s.GetUser(id)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return ErrVersionMismatch
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType — тип сообщения: текст (UTF-8) или двоичные данные
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// опкоды кадров (RFC 6455, 5.2)
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// коды закрытия (RFC 6455, 7.4.1)
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005 // только для получателя: кадр без кода
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseTooBig          = 1009
+	CloseInternalError   = 1011
+)
+
+const (
+	// maxControlPayload — у управляющих кадров тело не длиннее 125 байт
+	maxControlPayload = 125
+	// maxMessageBytes — предел сообщения, если Options его не задали
+	maxMessageBytes = 16 << 20
+	// writeFrameBytes — длинные сообщения уходят несколькими кадрами,
+	// чтобы не держать блокировку записи на всё сообщение
+	writeFrameBytes = 32 << 10
+)
+
+// ErrCloseSent — close-кадр уже отправлен, писать сообщения больше нельзя
+var ErrCloseSent = errors.New("websocket: close sent")
+
+// CloseError — соединение закрыто с кодом: клиентом или нами из-за нарушения
+// протокола
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Reason)
+}
+
+// Conn — WebSocket-соединение на стороне сервера. ReadMessage вызывается
+// из одной горутины, методы записи безопасны для параллельного вызова
+type Conn struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	maxMessage int64
+	readTO     time.Duration
+	writeTO    time.Duration
+
+	wmu       sync.Mutex
+	closeSent bool
+}
+
+func newConn(conn net.Conn, reader *bufio.Reader, opts Options) *Conn {
+	// дедлайны HTTP-запроса к сокету больше не относятся
+	conn.SetDeadline(time.Time{})
+	if opts.MaxMessageBytes <= 0 {
+		opts.MaxMessageBytes = maxMessageBytes
+	}
+	return &Conn{
+		conn:       conn,
+		reader:     reader,
+		maxMessage: opts.MaxMessageBytes,
+		readTO:     opts.ReadTimeout,
+		writeTO:    opts.WriteTimeout,
+	}
+}
+
+// RemoteAddr — адрес клиента
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// frame — заголовок кадра, тело уже размаскировано
+type frame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+// ReadMessage читает следующее сообщение, собирая фрагменты. На ping
+// отвечает pong сам, pong пропускает. На close отвечает close и возвращает
+// *CloseError с кодом клиента. Нарушение протокола закрывает соединение
+// с нужным кодом и тоже возвращает *CloseError
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var msgType MessageType
+	var msg []byte
+	for {
+		f, err := c.readFrame(c.maxMessage - int64(len(msg)))
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+
+		switch f.opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, f.payload, true); err != nil && err != ErrCloseSent {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, c.handleClose(f.payload)
+		case opText, opBinary:
+			if msgType != 0 {
+				return 0, nil, c.fail(protocolError("new message inside fragmented message"))
+			}
+			msgType = MessageType(f.opcode)
+		case opContinuation:
+			if msgType == 0 {
+				return 0, nil, c.fail(protocolError("continuation without message"))
+			}
+		}
+
+		msg = append(msg, f.payload...)
+		if f.fin {
+			if msgType == TextMessage && !utf8.Valid(msg) {
+				return 0, nil, c.fail(&CloseError{Code: CloseInvalidPayload, Reason: "invalid utf-8"})
+			}
+			return msgType, msg, nil
+		}
+	}
+}
+
+// readFrame читает кадр. limit — предел тела кадра данных (остаток от
+// MaxMessageBytes с учётом прошлых фрагментов). Длина проверяется
+// до чтения тела, чтобы не выделять память под чужой размер
+func (c *Conn) readFrame(limit int64) (frame, error) {
+	if c.readTO > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.readTO))
+	}
+	var head [2]byte
+	if _, err := io.ReadFull(c.reader, head[:]); err != nil {
+		return frame{}, err
+	}
+	f := frame{fin: head[0]&0x80 != 0, opcode: head[0] & 0x0f}
+	if head[0]&0x70 != 0 {
+		// RSV-биты занимают только расширения, а их мы не согласовывали
+		return frame{}, protocolError("reserved bits set")
+	}
+	switch f.opcode {
+	case opContinuation, opText, opBinary:
+	case opClose, opPing, opPong:
+		if !f.fin {
+			return frame{}, protocolError("fragmented control frame")
+		}
+	default:
+		return frame{}, protocolError(fmt.Sprintf("unknown opcode %#x", f.opcode))
+	}
+	if head[1]&0x80 == 0 {
+		return frame{}, protocolError("unmasked client frame")
+	}
+
+	length := int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return frame{}, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return frame{}, err
+		}
+		n := binary.BigEndian.Uint64(ext[:])
+		if n>>63 != 0 {
+			return frame{}, protocolError("invalid payload length")
+		}
+		length = int64(n)
+	}
+	if f.opcode >= opClose && length > maxControlPayload {
+		return frame{}, protocolError("control frame too long")
+	}
+	if f.opcode < opClose && length > limit {
+		return frame{}, &CloseError{Code: CloseTooBig, Reason: "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return frame{}, err
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, f.payload); err != nil {
+		return frame{}, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// handleClose отвечает на close клиента тем же кодом (RFC 6455, 5.5.1)
+func (c *Conn) handleClose(payload []byte) error {
+	code, reason := CloseNoStatus, ""
+	switch {
+	case len(payload) == 1:
+		return c.fail(protocolError("close payload too short"))
+	case len(payload) >= 2:
+		code = int(binary.BigEndian.Uint16(payload))
+		reason = string(payload[2:])
+		if !validCloseCode(code) {
+			return c.fail(protocolError(fmt.Sprintf("invalid close code %d", code)))
+		}
+		if !utf8.ValidString(reason) {
+			return c.fail(&CloseError{Code: CloseInvalidPayload, Reason: "invalid utf-8"})
+		}
+	}
+
+	var reply []byte
+	if code != CloseNoStatus {
+		reply = binary.BigEndian.AppendUint16(nil, uint16(code))
+	}
+	if err := c.writeFrame(opClose, reply, true); err != nil && err != ErrCloseSent {
+		return err
+	}
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// validCloseCode — код, который можно прислать в кадре (RFC 6455, 7.4)
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+func protocolError(reason string) *CloseError {
+	return &CloseError{Code: CloseProtocolError, Reason: reason}
+}
+
+// fail закрывает соединение из-за ошибки чтения: нарушению протокола
+// отвечает close-кадром с кодом, ошибку сети возвращает как есть
+func (c *Conn) fail(err error) error {
+	var ce *CloseError
+	if errors.As(err, &ce) {
+		c.WriteClose(ce.Code, ce.Reason)
+	}
+	return err
+}
+
+// WriteMessage отправляет сообщение. Длинное уходит несколькими кадрами,
+// и до последнего из них другие записи ждут — иначе кадры двух
+// сообщений перемешались бы
+func (c *Conn) WriteMessage(t MessageType, data []byte) error {
+	if t != TextMessage && t != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", t)
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	opcode := byte(t)
+	for {
+		chunk := data[:min(len(data), writeFrameBytes)]
+		data = data[len(chunk):]
+		if err := c.writeFrameLocked(opcode, chunk, len(data) == 0); err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		opcode = opContinuation
+	}
+}
+
+// Ping отправляет ping. Ответный pong продлевает ReadTimeout
+func (c *Conn) Ping(data []byte) error {
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: ping payload too long")
+	}
+	return c.writeFrame(opPing, data, true)
+}
+
+// WriteClose отправляет close-кадр с кодом и причиной. Длинная причина
+// обрезается по границе символа: клиент обязан отвергнуть невалидный UTF-8.
+// После close сообщения не пишутся; соединение закрывает обработчик,
+// дождавшись ответного close из ReadMessage или по таймауту
+func (c *Conn) WriteClose(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, truncateUTF8(reason, maxControlPayload-len(payload))...)
+	return c.writeFrame(opClose, payload, true)
+}
+
+// truncateUTF8 укорачивает s до n байт, не разрезая символ
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// Close закрывает TCP-соединение без close-кадра
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// writeFrame отправляет кадр одним Write. Сервер тело не маскирует
+func (c *Conn) writeFrame(opcode byte, payload []byte, fin bool) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.writeFrameLocked(opcode, payload, fin)
+}
+
+// writeFrameLocked — writeFrame под уже взятым wmu
+func (c *Conn) writeFrameLocked(opcode byte, payload []byte, fin bool) error {
+	if c.closeSent {
+		return ErrCloseSent
+	}
+
+	buf := make([]byte, 0, 10+len(payload))
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	buf = append(buf, b0)
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, byte(n))
+	case n <= 0xffff:
+		buf = append(buf, 126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	buf = append(buf, payload...)
+
+	if c.writeTO > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.writeTO))
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return err
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// clientFrame собирает маскированный кадр клиента
+func clientFrame(fin bool, opcode byte, payload []byte) []byte {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+// pipe возвращает серверный Conn и клиентский конец. Всё, что сервер
+// пишет, складывается в буфер, который отдаёт функция-результат
+func pipe(t *testing.T, opts Options) (*Conn, net.Conn, func() []byte) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() { server.Close(); client.Close() })
+
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&out, client)
+		close(done)
+	}()
+	written := func() []byte {
+		server.Close()
+		<-done
+		return out.Bytes()
+	}
+	return newConn(server, bufio.NewReader(server), opts), client, written
+}
+
+func TestReadFragmented(t *testing.T) {
+	c, client, written := pipe(t, Options{MaxMessageBytes: 1 << 20})
+	go func() {
+		// текст из трёх фрагментов с ping посередине и один двоичный кадр
+		client.Write(clientFrame(false, opText, []byte("hel")))
+		client.Write(clientFrame(true, opPing, []byte("p")))
+		client.Write(clientFrame(false, opContinuation, []byte("lo, ")))
+		client.Write(clientFrame(true, opContinuation, []byte("мир")))
+		client.Write(clientFrame(true, opBinary, bytes.Repeat([]byte{7}, 70000)))
+	}()
+
+	typ, msg, err := c.ReadMessage()
+	if err != nil || typ != TextMessage || string(msg) != "hello, мир" {
+		t.Fatalf("ReadMessage = %d %q %v", typ, msg, err)
+	}
+	typ, msg, err = c.ReadMessage()
+	if err != nil || typ != BinaryMessage || len(msg) != 70000 {
+		t.Fatalf("ReadMessage = %d len %d %v", typ, len(msg), err)
+	}
+	// pong с тем же телом, без маски
+	if out := written(); !bytes.Equal(out, []byte{0x80 | opPong, 1, 'p'}) {
+		t.Errorf("pong = %x", out)
+	}
+}
+
+func TestReadClose(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		code    int
+		reply   []byte
+	}{
+		{"with code", append([]byte{0x03, 0xe8}, "bye"...), 1000, []byte{0x88, 2, 0x03, 0xe8}},
+		{"private code", []byte{0x0f, 0xa0}, 4000, []byte{0x88, 2, 0x0f, 0xa0}},
+		{"empty", nil, CloseNoStatus, []byte{0x88, 0}},
+		{"one byte", []byte{0x03}, CloseProtocolError, nil},
+		{"code 1006", []byte{0x03, 0xee}, CloseProtocolError, nil},
+		{"invalid reason", []byte{0x03, 0xe8, 0xff}, CloseInvalidPayload, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, client, written := pipe(t, Options{})
+			go client.Write(clientFrame(true, opClose, tt.payload))
+
+			_, _, err := c.ReadMessage()
+			var ce *CloseError
+			if !errors.As(err, &ce) || ce.Code != tt.code {
+				t.Fatalf("err = %v, want close %d", err, tt.code)
+			}
+			out := written()
+			if tt.reply == nil {
+				// на нарушение — наш close с кодом ошибки
+				if len(out) < 4 || int(binary.BigEndian.Uint16(out[2:])) != tt.code {
+					t.Errorf("close reply = %x, want code %d", out, tt.code)
+				}
+			} else if !bytes.Equal(out, tt.reply) {
+				t.Errorf("close reply = %x, want %x", out, tt.reply)
+			}
+			if err := c.WriteMessage(TextMessage, []byte("late")); err != ErrCloseSent {
+				t.Errorf("write after close = %v", err)
+			}
+		})
+	}
+}
+
+func TestReadLengthLimit(t *testing.T) {
+	c, client, written := pipe(t, Options{MaxMessageBytes: 100})
+	// заявленная длина больше предела — тело даже не читается
+	go client.Write([]byte{0x82, 0x80 | 127, 0, 0, 0, 1, 0, 0, 0, 0})
+
+	if _, _, err := c.ReadMessage(); err == nil {
+		t.Fatal("oversized frame accepted")
+	}
+	if out := written(); len(out) < 4 || binary.BigEndian.Uint16(out[2:]) != CloseTooBig {
+		t.Errorf("close = %x, want 1009", out)
+	}
+}
+
+// без MaxMessageBytes действует maxMessageBytes: длина около 2^62
+// из заголовка не должна доходить до make
+func TestReadLengthDefaultLimit(t *testing.T) {
+	c, client, written := pipe(t, Options{})
+	go client.Write([]byte{0x82, 0x80 | 127, 0x3f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	var ce *CloseError
+	if _, _, err := c.ReadMessage(); !errors.As(err, &ce) || ce.Code != CloseTooBig {
+		t.Fatalf("err = %v, want close 1009", err)
+	}
+	if out := written(); len(out) < 4 || binary.BigEndian.Uint16(out[2:]) != CloseTooBig {
+		t.Errorf("close = %x, want 1009", out)
+	}
+}
+
+func TestWriteFragmented(t *testing.T) {
+	c, _, written := pipe(t, Options{})
+	msg := bytes.Repeat([]byte("x"), writeFrameBytes+10)
+	if err := c.WriteMessage(BinaryMessage, msg); err != nil {
+		t.Fatal(err)
+	}
+	out := written()
+
+	// первый кадр без FIN с двоичным опкодом, второй — продолжение с FIN
+	if out[0] != opBinary || out[1] != 126 || int(binary.BigEndian.Uint16(out[2:])) != writeFrameBytes {
+		t.Fatalf("first frame header = %x", out[:4])
+	}
+	second := out[4+writeFrameBytes:]
+	if !bytes.Equal(second[:2], []byte{0x80 | opContinuation, 10}) || len(second) != 12 {
+		t.Fatalf("second frame = %x", second)
+	}
+}
+
+// serverFrame — кадр сервера, разобранный из потока
+type serverFrame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+// serverFrames разбирает немаскированные кадры сервера
+func serverFrames(t *testing.T, out []byte) []serverFrame {
+	t.Helper()
+	var frames []serverFrame
+	for len(out) > 0 {
+		if len(out) < 2 {
+			t.Fatalf("truncated frame header %x", out)
+		}
+		f := serverFrame{fin: out[0]&0x80 != 0, opcode: out[0] & 0x0f}
+		n, head := int(out[1]&0x7f), 2
+		switch n {
+		case 126:
+			n, head = int(binary.BigEndian.Uint16(out[2:])), 4
+		case 127:
+			n, head = int(binary.BigEndian.Uint64(out[2:])), 10
+		}
+		if len(out) < head+n {
+			t.Fatalf("truncated frame payload")
+		}
+		f.payload = out[head : head+n]
+		frames = append(frames, f)
+		out = out[head+n:]
+	}
+	return frames
+}
+
+func TestWriteMessagesDoNotInterleave(t *testing.T) {
+	c, _, written := pipe(t, Options{})
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msg := bytes.Repeat([]byte{'a' + byte(i)}, 3*writeFrameBytes)
+			if err := c.WriteMessage(BinaryMessage, msg); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// каждое сообщение — кадр с опкодом, затем только его продолжения
+	messages, inMessage := 0, false
+	var fill byte
+	for _, f := range serverFrames(t, written()) {
+		if (f.opcode == opContinuation) != inMessage {
+			t.Fatalf("frame opcode %d while inMessage = %v", f.opcode, inMessage)
+		}
+		if !inMessage {
+			fill = f.payload[0]
+		}
+		if bytes.Count(f.payload, []byte{fill}) != len(f.payload) {
+			t.Fatal("fragments of different messages interleaved")
+		}
+		inMessage = !f.fin
+		if f.fin {
+			messages++
+		}
+	}
+	if messages != 4 || inMessage {
+		t.Fatalf("messages = %d, unfinished = %v", messages, inMessage)
+	}
+}
+
+func TestWriteCloseTruncatesReason(t *testing.T) {
+	c, _, written := pipe(t, Options{})
+	// «я» — два байта: 123 байта причины приходятся на середину символа
+	reason := strings.Repeat("я", 100)
+	if err := c.WriteClose(CloseGoingAway, reason); err != nil {
+		t.Fatal(err)
+	}
+	frames := serverFrames(t, written())
+	if len(frames) != 1 || frames[0].opcode != opClose {
+		t.Fatalf("frames = %+v", frames)
+	}
+	got := frames[0].payload[2:]
+	if len(frames[0].payload) > maxControlPayload || !utf8.Valid(got) || string(got) != strings.Repeat("я", 61) {
+		t.Fatalf("reason = %q (%d bytes)", got, len(got))
+	}
+}
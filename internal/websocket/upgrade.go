@@ -0,0 +1,136 @@
+// Package websocket — WebSocket (RFC 6455) поверх нашего HTTP-стека:
+// рукопожатие, кадры, фрагментация, ping/pong и закрытие с кодами.
+// Расширения (permessage-deflate) не поддерживаются
+package websocket
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+	"web-server/internal/httpx"
+)
+
+// keyGUID — константа из RFC 6455, 1.3 для Sec-WebSocket-Accept
+const keyGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Options — параметры соединения
+type Options struct {
+	// Subprotocols — поддерживаемые подпротоколы в порядке предпочтения
+	Subprotocols []string
+	// MaxMessageBytes — предел собранного сообщения, больше — закрытие с 1009.
+	// 0 — maxMessageBytes: без предела клиент заставил бы выделить память
+	// под любую длину из заголовка кадра
+	MaxMessageBytes int64
+	// ReadTimeout — сколько ждать любой кадр от клиента, 0 — без предела.
+	// Вместе с периодическим Ping обнаруживает пропавших клиентов
+	ReadTimeout time.Duration
+	// WriteTimeout — предел на отправку одного кадра
+	WriteTimeout time.Duration
+	// CheckOrigin решает, пускать ли страницу с чужого Origin.
+	// nil — только без Origin или с тем же хостом, что в Host
+	CheckOrigin func(r *httpx.Request) bool
+}
+
+// Upgrade проверяет запрос на рукопожатие и переключает соединение
+// на WebSocket. При ошибке ответ клиенту уже записан в w
+func Upgrade(w *httpx.ResponseWriter, r *httpx.Request, opts Options) (*Conn, error) {
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(405)
+		return nil, errors.New("websocket: method is not GET")
+	}
+	if !r.Headers.HasToken("Connection", "upgrade") || !r.Headers.HasToken("Upgrade", "websocket") {
+		// RFC 9110, 15.5.22: 426 подсказывает, на что переключиться
+		w.Header().Set("Upgrade", "websocket")
+		w.Header().Set("Connection", "Upgrade")
+		w.WriteHeader(426)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if r.Version != "HTTP/1.1" {
+		w.WriteHeader(400)
+		return nil, errors.New("websocket: upgrade requires HTTP/1.1")
+	}
+	if r.Headers.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		w.WriteHeader(426)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	keys := r.Headers.Values("Sec-WebSocket-Key")
+	if len(keys) != 1 || !validKey(keys[0]) {
+		w.WriteHeader(400)
+		return nil, errors.New("websocket: invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := opts.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		w.WriteHeader(403)
+		return nil, errors.New("websocket: origin not allowed")
+	}
+
+	w.Header().Set("Upgrade", "websocket")
+	w.Header().Set("Connection", "Upgrade")
+	w.Header().Set("Sec-WebSocket-Accept", acceptKey(keys[0]))
+	if protocol := selectSubprotocol(r, opts.Subprotocols); protocol != "" {
+		w.Header().Set("Sec-WebSocket-Protocol", protocol)
+	}
+	w.WriteHeader(101)
+	conn, reader, err := w.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	return newConn(conn, reader, opts), nil
+}
+
+// validKey — base64 от 16 случайных байт (RFC 6455, 4.1)
+func validKey(key string) bool {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(raw) == 16
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + keyGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// sameOrigin пускает клиентов без Origin (не браузеры) и страницы
+// с того же хоста. Иначе любой сайт мог бы открыть сокет от имени
+// пользователя с его cookie или клиентским сертификатом
+func sameOrigin(r *httpx.Request) bool {
+	origin := r.Headers.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Headers.Get("Host"))
+}
+
+// Subprotocols возвращает подпротоколы, предложенные клиентом
+func Subprotocols(r *httpx.Request) []string {
+	var list []string
+	for _, v := range r.Headers.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				list = append(list, p)
+			}
+		}
+	}
+	return list
+}
+
+// selectSubprotocol выбирает первый поддерживаемый сервером подпротокол
+func selectSubprotocol(r *httpx.Request, supported []string) string {
+	offered := Subprotocols(r)
+	for _, s := range supported {
+		for _, o := range offered {
+			if o == s {
+				return s
+			}
+		}
+	}
+	return ""
+}
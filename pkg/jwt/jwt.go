@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 	"web-server/internal/config"
+	"web-server/internal/httpx"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -30,13 +31,18 @@ func GenerateToken(userID int, cfg *config.Config) (string, error) {
 
 }
 
-func ParseToken(cfg *config.Config, headers map[string]string) (*Claims, error) {
-	auth := headers["Authorization"]
+// ParseToken достаёт Bearer-токен из заголовка Authorization и проверяет его
+func ParseToken(cfg *config.Config, headers httpx.Header) (*Claims, error) {
+	auth := headers.Get("Authorization")
 	if !strings.HasPrefix(auth, "Bearer ") {
 		return nil, fmt.Errorf("invalid authorization header")
 	}
-	tokenStr := strings.TrimPrefix(auth, "Bearer ")
+	return VerifyToken(cfg, strings.TrimPrefix(auth, "Bearer "))
+}
 
+// VerifyToken проверяет подпись и срок токена, полученного не из
+// Authorization — например, из подпротокола WebSocket
+func VerifyToken(cfg *config.Config, tokenStr string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method")
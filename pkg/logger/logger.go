@@ -10,7 +10,8 @@ import (
 
 const maxSize = 5 * 1024 * 1024
 
-var Log *slog.Logger
+// Log по умолчанию пишет в stderr, пока не вызван InitLogger
+var Log = slog.Default()
 var rw *rotatingWriter
 
 type rotatingWriter struct {
@@ -76,6 +77,13 @@ func InitLogger(logFile, logLevel string) error {
 	return nil
 }
 
+// Sync сбрасывает записанный лог на диск
+func Sync() {
+	if rw != nil {
+		rw.file.Sync()
+	}
+}
+
 // Close закрывает файл лога
 func CloseLogger() {
 	if rw != nil {